@@ -0,0 +1,72 @@
+// Package agent implements the xsops agent, a local daemon that keeps
+// decrypted vaults in memory and serves them to xsops commands over a
+// Unix socket that only the current user can access.
+//
+// Clients send the encrypted vault file with every request and the agent
+// caches the decrypted document by the hash of that file, so a changed
+// vault is never served from the cache. Cached documents are dropped when
+// they have not been used for the idle TTL or when the vault file changes
+// on disk.
+//
+// The protocol is one json encoded Request followed by one Response per
+// connection.
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultSocketEnv is the environment variable holding the socket path
+// of the agent that xsops commands use.
+const DefaultSocketEnv = "XSOPS_AGENT_SOCK"
+
+// Operations supported by the agent.
+const (
+	OpGet     = "get"
+	OpList    = "list"
+	OpSet     = "set"
+	OpEncrypt = "encrypt"
+	OpStatus  = "status"
+	OpStop    = "stop"
+)
+
+// CodeKeyNotFound is the error code returned when a key does not exist.
+const CodeKeyNotFound = "key_not_found"
+
+// Request is sent by a client to the agent.
+type Request struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// Response is sent by the agent for each request. Error is set when the
+// request failed and Code classifies well known errors.
+type Response struct {
+	Data   []byte  `json:"data,omitempty"`
+	Error  string  `json:"error,omitempty"`
+	Code   string  `json:"code,omitempty"`
+	Status *Status `json:"status,omitempty"`
+}
+
+// Status describes a running agent.
+type Status struct {
+	Pid    int      `json:"pid"`
+	TTL    string   `json:"ttl"`
+	Vaults []string `json:"vaults"`
+}
+
+// DefaultSocket returns the socket path used when XSOPS_AGENT_SOCK is not
+// set: xsops/agent.sock below XDG_RUNTIME_DIR or a user specific
+// directory in the temp directory.
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "xsops", "agent.sock")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("xsops-%d", os.Getuid()), "agent.sock")
+}
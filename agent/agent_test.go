@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+// countingBackend counts the calls to Decrypt.
+type countingBackend struct {
+	vault.PlaintextBackend
+	decrypts atomic.Int32
+}
+
+func (b *countingBackend) Decrypt(path string, data []byte) ([]byte, error) {
+	b.decrypts.Add(1)
+	return b.PlaintextBackend.Decrypt(path, data)
+}
+
+func startTestAgent(t *testing.T, backend vault.Backend) string {
+	t.Helper()
+
+	// Unix socket paths are limited in length, so avoid t.TempDir.
+	dir, err := os.MkdirTemp("", "xsops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "agent.sock")
+	server := NewServer(backend, 0)
+	if err := server.Listen(socket); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	return socket
+}
+
+func TestClientVault(t *testing.T) {
+	backend := &countingBackend{}
+	socket := startTestAgent(t, backend)
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	path := filepath.Join(t.TempDir(), "xsops.secrets.json")
+	v, err := vault.Create(path, vault.WithBackend(NewClient(socket, nil)))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	before := backend.decrypts.Load()
+	for i := 0; i < 3; i++ {
+		record, err := v.Get("a")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		if record.Secret != "1" {
+			t.Errorf("Get() = %q, want %q", record.Secret, "1")
+		}
+	}
+
+	if n := backend.decrypts.Load() - before; n != 1 {
+		t.Errorf("the vault was decrypted %d times, want 1", n)
+	}
+
+	if _, err := v.Set("a", "2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	record, err := v.Get("a")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if record.Secret != "2" {
+		t.Errorf("Get() after change = %q, want %q", record.Secret, "2")
+	}
+
+	if _, err := v.Get("missing"); !errors.Is(err, vault.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, vault.ErrKeyNotFound)
+	}
+
+	keys, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("List() = %v, want [a]", keys)
+	}
+
+	status, err := NewClient(socket, nil).Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	if len(status.Vaults) != 1 || status.Vaults[0] != path {
+		t.Errorf("Status().Vaults = %v, want [%s]", status.Vaults, path)
+	}
+}
+
+func TestClientFallback(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	path := filepath.Join(t.TempDir(), "xsops.secrets.json")
+
+	if _, err := vault.Create(path, vault.WithBackend(NewClient(socket, &vault.PlaintextBackend{}))); err != nil {
+		t.Errorf("Create() with fallback error = %v", err)
+	}
+
+	if _, err := NewClient(socket, nil).Decrypt(path, []byte("{}")); err == nil {
+		t.Error("Decrypt() without agent or fallback did not fail")
+	}
+}
+
+func TestStop(t *testing.T) {
+	socket := startTestAgent(t, &vault.PlaintextBackend{})
+
+	if err := NewClient(socket, nil).Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if _, err := NewClient(socket, nil).Status(); err == nil {
+		t.Error("Status() after Stop() did not fail")
+	}
+}
+
+func TestListenPrivateDir(t *testing.T) {
+	dir, err := os.MkdirTemp("", "xsops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	shared := filepath.Join(dir, "shared")
+	if err := os.Mkdir(shared, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewServer(&vault.PlaintextBackend{}, 0).Listen(filepath.Join(shared, "agent.sock")); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("Listen() in a shared directory error = %v, want ErrUntrusted", err)
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(t.TempDir(), link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewServer(&vault.PlaintextBackend{}, 0).Listen(filepath.Join(link, "agent.sock")); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("Listen() in a symlinked directory error = %v, want ErrUntrusted", err)
+	}
+
+	server := NewServer(&vault.PlaintextBackend{}, 0)
+	if err := server.Listen(filepath.Join(dir, "new", "agent.sock")); err != nil {
+		t.Fatalf("Listen() in a new directory error = %v", err)
+	}
+	server.Close()
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+// dialTimeout is how long a client waits to connect to the agent.
+const dialTimeout = time.Second
+
+// Client is a vault.Backend that forwards every operation to the agent
+// listening on Socket. When the agent cannot be reached the operations
+// use Fallback instead, if it is set.
+type Client struct {
+	Socket   string
+	Fallback vault.Backend
+}
+
+var _ vault.Backend = (*Client)(nil)
+
+// NewClient returns a client for the agent listening on socket.
+func NewClient(socket string, fallback vault.Backend) *Client {
+	return &Client{Socket: socket, Fallback: fallback}
+}
+
+// Decrypt implements vault.Backend.
+func (c *Client) Decrypt(path string, data []byte) ([]byte, error) {
+	out, err := c.call(&Request{Op: OpList, Path: path, Data: data})
+	if c.fallback(err) {
+		return c.Fallback.Decrypt(path, data)
+	}
+
+	return out, err
+}
+
+// Encrypt implements vault.Backend.
+func (c *Client) Encrypt(path string, plaintext []byte, current []byte) ([]byte, error) {
+	out, err := c.call(&Request{Op: OpEncrypt, Path: path, Data: current, Value: plaintext})
+	if c.fallback(err) {
+		return c.Fallback.Encrypt(path, plaintext, current)
+	}
+
+	return out, err
+}
+
+// Extract implements vault.Backend.
+func (c *Client) Extract(path string, data []byte, key string) ([]byte, error) {
+	out, err := c.call(&Request{Op: OpGet, Path: path, Data: data, Key: key})
+	if c.fallback(err) {
+		return c.Fallback.Extract(path, data, key)
+	}
+
+	return out, err
+}
+
+// Set implements vault.Backend.
+func (c *Client) Set(path string, data []byte, key string, value []byte) ([]byte, error) {
+	out, err := c.call(&Request{Op: OpSet, Path: path, Data: data, Key: key, Value: value})
+	if c.fallback(err) {
+		return c.Fallback.Set(path, data, key, value)
+	}
+
+	return out, err
+}
+
+// Status returns the status of the agent.
+func (c *Client) Status() (*Status, error) {
+	resp, err := c.roundTrip(&Request{Op: OpStatus})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Status, nil
+}
+
+// Stop stops the agent, which drops every cached vault.
+func (c *Client) Stop() error {
+	_, err := c.roundTrip(&Request{Op: OpStop})
+	return err
+}
+
+// fallback reports whether err means the agent could not be reached and
+// a fallback backend is available.
+func (c *Client) fallback(err error) bool {
+	var dialErr *dialError
+	return c.Fallback != nil && errors.As(err, &dialErr)
+}
+
+func (c *Client) call(req *Request) ([]byte, error) {
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+func (c *Client) roundTrip(req *Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", c.Socket, dialTimeout)
+	if err != nil {
+		return nil, &dialError{err: err}
+	}
+	defer conn.Close()
+
+	// Never send plaintext to, or trust values from, an agent run by
+	// another user.
+	if err := checkPeer(conn); err != nil {
+		return nil, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	resp := &Response{}
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		if resp.Code == CodeKeyNotFound {
+			return nil, vault.ErrKeyNotFound
+		}
+		return nil, &Error{Msg: resp.Error}
+	}
+
+	return resp, nil
+}
+
+// Error is an error returned by the agent.
+type Error struct {
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return "agent: " + e.Msg
+}
+
+// dialError reports that the agent could not be reached.
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return "agent: " + e.err.Error()
+}
+
+func (e *dialError) Unwrap() error {
+	return e.err
+}
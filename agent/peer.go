@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// ErrUntrusted is returned when the socket directory or the process at the
+// other end of the socket does not belong to the current user.
+var ErrUntrusted = errors.New("agent socket is not owned by the current user")
+
+// errNoPeerCredentials is returned by peerUID on platforms that cannot
+// tell who is at the other end of a unix socket.
+var errNoPeerCredentials = errors.New("peer credentials are not supported")
+
+// checkPeer verifies that the process at the other end of conn runs as the
+// current user. Platforms without peer credentials rely on the socket
+// directory being private.
+func checkPeer(conn net.Conn) error {
+	uid, err := peerUID(conn)
+	if errors.Is(err, errNoPeerCredentials) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading peer credentials: %w", err)
+	}
+
+	if uid != os.Getuid() {
+		return fmt.Errorf("%w: peer runs as uid %d", ErrUntrusted, uid)
+	}
+
+	return nil
+}
+
+// privateDir creates the directory dir of the socket when it does not
+// exist and verifies that it is a directory, not a symlink, owned by the
+// current user and only accessible to them, so no other user can create
+// or replace the socket.
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrUntrusted, dir)
+	}
+
+	if err := checkPrivate(info); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+
+	return nil
+}
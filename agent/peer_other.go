@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package agent
+
+import "net"
+
+func peerUID(conn net.Conn) (int, error) {
+	return 0, errNoPeerCredentials
+}
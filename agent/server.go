@@ -0,0 +1,305 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+// DefaultTTL is how long a decrypted vault is kept after its last use.
+const DefaultTTL = 15 * time.Minute
+
+// checkInterval is how often the agent looks for idle or changed vaults.
+const checkInterval = time.Second
+
+// Server is the agent daemon. It decrypts vaults with Backend and caches
+// the decrypted documents for TTL after their last use.
+type Server struct {
+	Backend vault.Backend
+	TTL     time.Duration
+
+	mu       sync.Mutex
+	cache    map[string]*entry
+	listener net.Listener
+	socket   string
+	done     chan struct{}
+	once     sync.Once
+}
+
+// entry is a decrypted vault held in memory.
+type entry struct {
+	hash    [sha256.Size]byte
+	plain   []byte
+	doc     map[string]json.RawMessage
+	modTime time.Time
+	size    int64
+	used    time.Time
+}
+
+// NewServer returns a server that decrypts vaults with backend.
+func NewServer(backend vault.Backend, ttl time.Duration) *Server {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Server{
+		Backend: backend,
+		TTL:     ttl,
+		cache:   map[string]*entry{},
+		done:    make(chan struct{}),
+	}
+}
+
+// Listen creates the socket at path with user only permissions. The
+// directory of path is created when needed and must be owned by the
+// current user with mode 0700, otherwise ErrUntrusted is returned. A
+// socket left behind by an agent that is no longer running is replaced.
+func (s *Server) Listen(path string) error {
+	if err := privateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("an agent is already listening on %s", path)
+		}
+		os.Remove(path)
+	}
+
+	l, err := listen(path)
+	if err != nil {
+		return err
+	}
+
+	s.listener = l
+	s.socket = path
+	return nil
+}
+
+// Serve accepts connections until Close is called or a stop request is
+// received.
+func (s *Server) Serve() error {
+	go s.janitor()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// Close stops the server, removes the socket and drops every cached vault.
+func (s *Server) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		if s.listener != nil {
+			err = s.listener.Close()
+			os.Remove(s.socket)
+		}
+
+		s.mu.Lock()
+		for path, e := range s.cache {
+			e.wipe()
+			delete(s.cache, path)
+		}
+		s.mu.Unlock()
+	})
+
+	return err
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	// Only serve the user the agent runs as.
+	if err := checkPeer(conn); err != nil {
+		json.NewEncoder(conn).Encode(&Response{Error: err.Error()})
+		return
+	}
+
+	req := &Request{}
+	if err := json.NewDecoder(conn).Decode(req); err != nil {
+		json.NewEncoder(conn).Encode(&Response{Error: err.Error()})
+		return
+	}
+
+	resp := s.do(req)
+	if req.Op == OpStop {
+		// Stop listening before replying so the agent is gone once the
+		// client sees the reply.
+		s.Close()
+	}
+
+	json.NewEncoder(conn).Encode(resp)
+}
+
+func (s *Server) do(req *Request) *Response {
+	var data []byte
+	var err error
+
+	switch req.Op {
+	case OpStatus:
+		return &Response{Status: s.status()}
+	case OpStop:
+		return &Response{}
+	case OpList:
+		data, err = s.decrypt(req.Path, req.Data)
+	case OpGet:
+		data, err = s.get(req.Path, req.Data, req.Key)
+	case OpSet:
+		data, err = s.Backend.Set(req.Path, req.Data, req.Key, req.Value)
+	case OpEncrypt:
+		data, err = s.Backend.Encrypt(req.Path, req.Value, req.Data)
+	default:
+		err = fmt.Errorf("unknown operation '%s'", req.Op)
+	}
+
+	if err != nil {
+		resp := &Response{Error: err.Error()}
+		if errors.Is(err, vault.ErrKeyNotFound) {
+			resp.Code = CodeKeyNotFound
+		}
+		return resp
+	}
+
+	return &Response{Data: data}
+}
+
+// decrypt returns the decrypted document of the vault file contents data
+// read from path, decrypting it only when it is not cached.
+func (s *Server) decrypt(path string, data []byte) ([]byte, error) {
+	return s.lookup(path, data, func(e *entry) ([]byte, error) {
+		return bytes.Clone(e.plain), nil
+	})
+}
+
+// get returns the record stored under key in the vault file contents
+// data read from path.
+func (s *Server) get(path string, data []byte, key string) ([]byte, error) {
+	return s.lookup(path, data, func(e *entry) ([]byte, error) {
+		raw, ok := e.doc[key]
+		if !ok {
+			return nil, vault.ErrKeyNotFound
+		}
+
+		return bytes.Clone(raw), nil
+	})
+}
+
+// lookup calls fn with the cached entry of the vault file contents data,
+// decrypting and caching it first when needed. fn is called with the
+// cache locked so the entry cannot be wiped while it is used.
+func (s *Server) lookup(path string, data []byte, fn func(e *entry) ([]byte, error)) ([]byte, error) {
+	hash := sha256.Sum256(data)
+
+	s.mu.Lock()
+	if e, ok := s.cache[path]; ok && e.hash == hash {
+		e.used = time.Now()
+		defer s.mu.Unlock()
+		return fn(e)
+	}
+	s.mu.Unlock()
+
+	plain, err := s.Backend.Decrypt(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(plain, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "sops")
+
+	e := &entry{hash: hash, plain: plain, doc: doc, used: time.Now()}
+	if info, err := os.Stat(path); err == nil {
+		e.modTime = info.ModTime()
+		e.size = info.Size()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.cache[path]; ok {
+		old.wipe()
+	}
+	s.cache[path] = e
+
+	return fn(e)
+}
+
+// janitor drops cached vaults that have been idle for the TTL or whose
+// file changed on disk.
+func (s *Server) janitor() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for path, e := range s.cache {
+				if now.Sub(e.used) > s.TTL || e.changed(path) {
+					e.wipe()
+					delete(s.cache, path)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) status() *Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vaults := make([]string, 0, len(s.cache))
+	for path := range s.cache {
+		vaults = append(vaults, path)
+	}
+	sort.Strings(vaults)
+
+	return &Status{Pid: os.Getpid(), TTL: s.TTL.String(), Vaults: vaults}
+}
+
+// changed reports whether the vault file at path differs from the one
+// that was decrypted.
+func (e *entry) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+
+	return !info.ModTime().Equal(e.modTime) || info.Size() != e.size
+}
+
+// wipe overwrites the decrypted document so it does not linger in memory.
+func (e *entry) wipe() {
+	clear(e.plain)
+	for _, raw := range e.doc {
+		clear(raw)
+	}
+	e.doc = nil
+}
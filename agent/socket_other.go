@@ -0,0 +1,16 @@
+//go:build !unix
+
+package agent
+
+import (
+	"net"
+	"os"
+)
+
+func checkPrivate(info os.FileInfo) error {
+	return nil
+}
+
+func listen(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
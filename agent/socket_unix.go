@@ -0,0 +1,38 @@
+//go:build unix
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkPrivate verifies that the file described by info belongs to the
+// current user and is not accessible to anyone else.
+func checkPrivate(info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%w: unknown owner", ErrUntrusted)
+	}
+
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: owned by uid %d", ErrUntrusted, st.Uid)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0700 {
+		return fmt.Errorf("%w: mode %04o, want 0700", ErrUntrusted, perm)
+	}
+
+	return nil
+}
+
+// listen creates the unix socket at path under a umask that leaves it
+// accessible to the current user only from the moment it exists.
+func listen(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+
+	return net.Listen("unix", path)
+}
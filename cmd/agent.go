@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/hyprxlabs/xsops/agent"
+	"github.com/spf13/cobra"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run an agent that keeps decrypted vaults in memory",
+	Long: `Run an agent that keeps decrypted vaults in memory and serves them to
+other xsops commands over a Unix socket that only the current user can use.
+
+Commands use the agent when the XSOPS_AGENT_SOCK environment variable is set
+to its socket, which avoids decrypting the vault on every call in scripts
+that run xsops many times. When the agent is not running, commands decrypt
+the vault themselves.
+
+A decrypted vault is dropped from memory when it has not been used for the
+--ttl duration or when the vault file changes. The agent runs until it is
+interrupted or "xsops agent stop" is called.`,
+	Example: `xsops agent &
+export XSOPS_AGENT_SOCK="$(xsops agent socket)"
+xsops get my-secret
+xsops agent stop`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		socket := agentSocket(cmd)
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+
+		// The agent decrypts the vaults itself and must never use another
+		// agent.
+		backend, err := getLocalBackend("", nil)
+		if err != nil {
+			errorf("Error selecting backend: %v", err)
+			os.Exit(1)
+		}
+
+		server := agent.NewServer(backend, ttl)
+		if err := server.Listen(socket); err != nil {
+			errorf("Error starting agent: %v", err)
+			os.Exit(1)
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			<-signals
+			server.Close()
+		}()
+
+		fmt.Fprintf(os.Stderr, "xsops agent listening on %s\n", socket)
+		fmt.Fprintf(os.Stderr, "export %s=%s\n", agent.DefaultSocketEnv, quotePosix(socket))
+
+		if err := server.Serve(); err != nil {
+			errorf("Error running agent: %v", err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	},
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the agent is running and the vaults it holds",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		socket := agentSocket(cmd)
+		status, err := agent.NewClient(socket, nil).Status()
+		if err != nil {
+			errorf("No agent is running on %s: %v", socket, err)
+			os.Exit(1)
+		}
+
+		printOutput(cmd, status, func(w io.Writer) {
+			fmt.Fprintf(w, "socket: %s\npid: %d\nttl: %s\n", socket, status.Pid, status.TTL)
+			if len(status.Vaults) == 0 {
+				fmt.Fprintln(w, "vaults: none")
+			} else {
+				fmt.Fprintf(w, "vaults:\n  %s\n", strings.Join(status.Vaults, "\n  "))
+			}
+		}, nil)
+		os.Exit(0)
+	},
+}
+
+var agentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the agent and drop every decrypted vault",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		socket := agentSocket(cmd)
+		if err := agent.NewClient(socket, nil).Stop(); err != nil {
+			errorf("No agent is running on %s: %v", socket, err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	},
+}
+
+var agentSocketCmd = &cobra.Command{
+	Use:   "socket",
+	Short: "Print the socket path of the agent",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(agentSocket(cmd))
+		os.Exit(0)
+	},
+}
+
+// agentSocket returns the socket from the --socket flag, XSOPS_AGENT_SOCK
+// or the default location.
+func agentSocket(cmd *cobra.Command) string {
+	socket, _ := cmd.Flags().GetString("socket")
+	if socket == "" {
+		socket = os.Getenv(agent.DefaultSocketEnv)
+	}
+
+	if socket == "" {
+		socket = agent.DefaultSocket()
+	}
+
+	return socket
+}
+
+func init() {
+	agentCmd.PersistentFlags().String("socket", "", "Path of the agent socket (default $XSOPS_AGENT_SOCK or a user only runtime directory)")
+	agentCmd.Flags().Duration("ttl", agent.DefaultTTL, "Drop a decrypted vault after it has not been used for this long")
+	agentCmd.AddCommand(agentStatusCmd)
+	agentCmd.AddCommand(agentStopCmd)
+	agentCmd.AddCommand(agentSocketCmd)
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "List and restore backups of the vault",
+	Long: `List and restore backups of the vault.
+
+Before every change xsops keeps the encrypted vault as a backup in the
+backups directory of the data home, for example ~/.local/share/xsops/backups
+on Linux. The number of backups kept for each vault is set with the backups
+key in config.toml and defaults to 10. Backups stay encrypted with the keys
+the vault used at the time.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var backupsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the backups of the vault, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		backups, err := v.Backups()
+		if err != nil {
+			errorf("Error listing backups: %v", err)
+			os.Exit(1)
+		}
+
+		writeTable := func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "ID\tTIME\tSIZE")
+			for _, b := range backups {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", b.ID, b.Time.Local().Format("2006-01-02 15:04:05"), b.Size)
+			}
+		}
+
+		printOutput(cmd, backups, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			writeTable(tw)
+			tw.Flush()
+		}, writeTable)
+		os.Exit(0)
+	},
+}
+
+var backupsRestoreCmd = &cobra.Command{
+	Use:   "restore ID",
+	Short: "Restore a backup of the vault",
+	Long: `Restore a backup of the vault by its ID as shown by "xsops backups ls".
+
+The current vault is backed up before it is replaced, so a restore can be
+reverted with "xsops undo".`,
+	Example: `xsops backups restore 20250101T120000.000000000Z`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		b, err := v.Restore(args[0])
+		if err != nil {
+			if errors.Is(err, vault.ErrNoBackup) {
+				errorf("Backup '%s' does not exist for %s.", args[0], v.Path())
+				os.Exit(1)
+			}
+
+			errorf("Error restoring backup: %v", err)
+			os.Exit(1)
+		}
+
+		printBackupRestored(cmd, b)
+		os.Exit(0)
+	},
+}
+
+// printBackupRestored reports that the backup b was restored.
+func printBackupRestored(cmd *cobra.Command, b *vault.Backup) {
+	printOutput(cmd, b, func(w io.Writer) {
+		infof("Restored the backup from %s.", b.Time.Local().Format("2006-01-02 15:04:05"))
+	}, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tTIME\tSIZE")
+		fmt.Fprintf(w, "%s\t%s\t%d\n", b.ID, b.Time.Local().Format("2006-01-02 15:04:05"), b.Size)
+	})
+}
+
+func init() {
+	backupsCmd.AddCommand(backupsLsCmd)
+	backupsCmd.AddCommand(backupsRestoreCmd)
+	rootCmd.AddCommand(backupsCmd)
+}
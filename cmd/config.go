@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyprxlabs/xsops/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change the defaults in config.toml",
+	Long: `Show and change the defaults in config.toml.
+
+config.toml lives in the config home, for example ~/.config/xsops/config.toml
+on Linux, and sets defaults such as the vault, backend, editor, output format
+and the generator settings of ensure. Run "xsops config list" for every key.
+
+Settings other than vault can be overridden for a single vault in a
+[vaults.NAME] table, where NAME is the value of --vault such as a registered
+vault name:
+
+    backend = "sops"
+
+    [ensure]
+    size = 48
+
+    [vaults.prod]
+    backups = 50
+
+Each key can also be set with an XSOPS_* environment variable, for example
+XSOPS_BACKEND or XSOPS_ENSURE_SIZE, which takes precedence over config.toml.
+Command line flags take precedence over both.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print the value of a config key",
+	Long: `Print the value of a config key as used by the vault selected with
+--vault, including environment overrides. Use vaults.NAME.KEY to get the
+value for the vault NAME.`,
+	Example: `xsops config get backend
+xsops -v prod config get ensure.size
+xsops config get vaults.prod.backups`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, name, err := config.LookupSetting(args[0])
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+
+		if name == "" && s.PerVault {
+			name = getVaultName(cmd)
+		}
+
+		result := newConfigOutput(s, name)
+		if result.Source == "" {
+			errorf("%s is not set.", args[0])
+			os.Exit(1)
+		}
+
+		printOutput(cmd, result, func(w io.Writer) {
+			fmt.Fprintln(w, result.Value)
+		}, func(w *tabwriter.Writer) {
+			writeConfigTable(w, []configOutput{result})
+		})
+		os.Exit(0)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: "Set a config key in config.toml",
+	Long: `Set a config key in config.toml. Use vaults.NAME.KEY to set the value
+for the vault NAME only.`,
+	Example: `xsops config set backend sops-exec
+xsops config set ensure.size 48
+xsops config set vaults.prod.backups 50`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.SetValue(args[0], args[1]); err != nil {
+			errorf("Error setting %s: %v", args[0], err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the config keys and their values",
+	Long: `List every config key with the value used by the vault selected with
+--vault and where the value comes from: an environment variable, a key of
+config.toml or "-" when it is not set.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := getVaultName(cmd)
+		results := make([]configOutput, 0, len(config.Settings))
+		for i := range config.Settings {
+			s := &config.Settings[i]
+			if s.PerVault {
+				results = append(results, newConfigOutput(s, name))
+			} else {
+				results = append(results, newConfigOutput(s, ""))
+			}
+		}
+
+		printOutput(cmd, results, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			writeConfigTable(tw, results)
+			tw.Flush()
+		}, func(w *tabwriter.Writer) {
+			writeConfigTable(w, results)
+		})
+		os.Exit(0)
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the path of config.toml",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := config.ConfigPath()
+		if err != nil {
+			errorf("Error getting the config home: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(path)
+		os.Exit(0)
+	},
+}
+
+// configOutput is a config key as emitted by the config commands. Source
+// is empty when the key is not set.
+type configOutput struct {
+	Key         string `json:"key" yaml:"key"`
+	Value       string `json:"value" yaml:"value"`
+	Source      string `json:"source,omitempty" yaml:"source,omitempty"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// newConfigOutput looks up the setting s for the vault named vaultName.
+func newConfigOutput(s *config.Setting, vaultName string) configOutput {
+	cfg, err := getConfig()
+	if err != nil {
+		warnf("Error reading config.toml: %v", err)
+	}
+
+	value, source, _ := config.Lookup(cfg, vaultName, s.Key)
+	return configOutput{Key: s.Key, Value: value, Source: source, Description: s.Description}
+}
+
+func writeConfigTable(w *tabwriter.Writer, settings []configOutput) {
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tDESCRIPTION")
+	for _, s := range settings {
+		value, source := s.Value, s.Source
+		if source == "" {
+			value, source = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, value, source, s.Description)
+	}
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configPathCmd)
+	rootCmd.AddCommand(configCmd)
+}
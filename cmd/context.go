@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyprxlabs/xsops/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Switch the active vault",
+	Long: `Switch the active vault without passing --vault to every command.
+
+The active context is the name of a vault registered with "xsops vault add",
+or default for the vault in the data home. It is stored in the config home
+and used when neither --vault nor XSOPS_VAULT is set.`,
+	Example: `xsops context use staging
+xsops get db-password
+xsops context current
+xsops context use --clear`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use [NAME]",
+	Short: "Make a registered vault the active context",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clearContext, _ := cmd.Flags().GetBool("clear")
+		if clearContext {
+			if err := config.SetContext(""); err != nil {
+				errorf("Error clearing the context: %v", err)
+				os.Exit(1)
+			}
+
+			infof("Cleared the active context.")
+			os.Exit(0)
+		}
+
+		if len(args) == 0 {
+			errorf("You must provide the NAME of a registered vault or --clear.")
+			usagef("Usage: xsops context use NAME")
+			os.Exit(1)
+		}
+
+		name := args[0]
+		if name != "default" {
+			registry, err := config.LoadRegistry()
+			if err != nil {
+				errorf("Error reading the vault registry: %v", err)
+				os.Exit(1)
+			}
+
+			if _, ok := registry.Get(name); !ok {
+				errorf("Vault '%s' is not registered, add it with \"xsops vault add\".", name)
+				os.Exit(1)
+			}
+		}
+
+		if err := config.SetContext(name); err != nil {
+			errorf("Error setting the context: %v", err)
+			os.Exit(1)
+		}
+
+		infof("Switched to context '%s'.", name)
+		os.Exit(0)
+	},
+}
+
+var contextLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the contexts, marking the active one",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			errorf("Error reading the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		current, err := config.GetContext()
+		if err != nil {
+			errorf("Error reading the active context: %v", err)
+			os.Exit(1)
+		}
+
+		results := []contextOutput{}
+		for _, entry := range registry.Entries() {
+			results = append(results, contextOutput{Name: entry.Name, Path: entry.Path, Active: entry.Name == current})
+		}
+
+		if path, err := getFilePath("default"); err == nil {
+			results = append(results, contextOutput{Name: "default", Path: path, Active: current == "default"})
+		}
+
+		printOutput(cmd, results, func(w io.Writer) {
+			for _, r := range results {
+				mark := " "
+				if r.Active {
+					mark = "*"
+				}
+				fmt.Fprintf(w, "%s %s\n", mark, r.Name)
+			}
+		}, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "CURRENT\tNAME\tPATH")
+			for _, r := range results {
+				mark := ""
+				if r.Active {
+					mark = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", mark, r.Name, r.Path)
+			}
+		})
+		os.Exit(0)
+	},
+}
+
+var contextCurrentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Print the active context",
+	Long: `Print the active context. It exits with 1 when no context is active.
+
+The context is only used when neither --vault nor XSOPS_VAULT is set; run
+with --debug to see which vault a command uses and why.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		current, err := config.GetContext()
+		if err != nil {
+			errorf("Error reading the active context: %v", err)
+			os.Exit(1)
+		}
+
+		if current == "" {
+			errorf("No context is active.")
+			os.Exit(1)
+		}
+
+		path, _ := getFilePath(current)
+		result := contextOutput{Name: current, Path: path, Active: true}
+		printOutput(cmd, result, func(w io.Writer) {
+			fmt.Fprintln(w, current)
+		}, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "NAME\tPATH")
+			fmt.Fprintf(w, "%s\t%s\n", result.Name, result.Path)
+		})
+		os.Exit(0)
+	},
+}
+
+// contextOutput is a context as emitted by the context commands.
+type contextOutput struct {
+	Name   string `json:"name" yaml:"name"`
+	Path   string `json:"path" yaml:"path"`
+	Active bool   `json:"active" yaml:"active"`
+}
+
+func init() {
+	contextUseCmd.Flags().Bool("clear", false, "Clear the active context")
+
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextLsCmd)
+	contextCmd.AddCommand(contextCurrentCmd)
+	rootCmd.AddCommand(contextCmd)
+}
@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/gobwas/glob"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff FROM [TO]",
+	Short: "Compare the secrets of two vaults without showing them",
+	Long: `Compare the secrets of the vault FROM with the vault TO and list the keys
+that are missing from TO, extra in TO or changed. TO defaults to the
+selected vault, so "xsops diff git:HEAD" shows the changes since the last
+commit. Secret values are never shown: a changed value is reported
+as "secret" and --hashes adds a short fingerprint of each value, an HMAC
+keyed by a random key stored in the vault and encrypted whenever its
+secrets are, so checking a fingerprint against a guessed value needs the
+vault to be decrypted. Both sides use the key of FROM, or of TO when FROM
+has no fingerprint key.
+
+Besides the secret the enabled state, expiry, tags and generator settings
+are compared. Dates of creation and update, versions and history differ
+between vaults by nature and are ignored.
+
+Vaults are given as a path, URI or registered name, or as git:REV:PATH for
+the version of a vault committed at the git revision REV. git:REV alone is
+the selected vault at that revision.
+
+The exit code is 0 when the vaults have the same secrets, 1 when they
+differ and 2 when a vault cannot be read, like diff.`,
+	Example: `xsops diff staging prod
+xsops diff -v ./dev.secrets.json git:HEAD
+xsops diff git:HEAD~3:prod.secrets.json prod
+xsops diff git:main:xsops.secrets.json ./xsops.secrets.json --hashes`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		match, _ := cmd.Flags().GetString("match")
+		hashes, _ := cmd.Flags().GetBool("hashes")
+
+		var g glob.Glob
+		if match != "" {
+			var err error
+			if g, err = vault.CompileGlob(match); err != nil {
+				errorf("Invalid --match pattern '%s': %v", match, err)
+				os.Exit(2)
+			}
+		}
+
+		if len(args) == 1 {
+			args = append(args, getVaultName(cmd))
+		}
+
+		from, err := loadSecrets(cmd, args[0])
+		if err != nil {
+			errorf("Error reading %s: %v", args[0], err)
+			os.Exit(2)
+		}
+
+		to, err := loadSecrets(cmd, args[1])
+		if err != nil {
+			errorf("Error reading %s: %v", args[1], err)
+			os.Exit(2)
+		}
+
+		if g != nil {
+			for _, records := range []map[string]*vault.SecretRecord{from.Records, to.Records} {
+				for key := range records {
+					if !g.Match(key) {
+						delete(records, key)
+					}
+				}
+			}
+		}
+
+		var fingerprint func(secret string) string
+		if hashes {
+			// Both sides use the key of one vault so their fingerprints
+			// can be compared.
+			keyed := from
+			if !keyed.CanFingerprint() {
+				keyed = to
+			}
+
+			if keyed.CanFingerprint() {
+				fingerprint = keyed.Fingerprint
+			} else {
+				warnf("Neither vault has a fingerprint key yet, it is added on their next change.")
+			}
+		}
+
+		results := diffSecrets(from.Records, to.Records, fingerprint)
+		printOutput(cmd, results, func(w io.Writer) {
+			if len(results) == 0 {
+				return
+			}
+
+			fmt.Fprintf(w, "--- %s\n+++ %s\n", args[0], args[1])
+			for _, r := range results {
+				switch r.Status {
+				case "missing":
+					color.New(color.FgRed).Fprintln(w, "- "+r.Key+formatHashes(r))
+				case "extra":
+					color.New(color.FgGreen).Fprintln(w, "+ "+r.Key+formatHashes(r))
+				default:
+					color.New(color.FgYellow).Fprintln(w, "~ "+r.Key+": "+strings.Join(r.Changes, ", ")+formatHashes(r))
+				}
+			}
+		}, func(w *tabwriter.Writer) {
+			if hashes {
+				fmt.Fprintln(w, "KEY\tSTATUS\tCHANGES\tFROM\tTO")
+			} else {
+				fmt.Fprintln(w, "KEY\tSTATUS\tCHANGES")
+			}
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s", r.Key, r.Status, orDash(strings.Join(r.Changes, ", ")))
+				if hashes {
+					fmt.Fprintf(w, "\t%s\t%s", orDash(r.FromHash), orDash(r.ToHash))
+				}
+				fmt.Fprintln(w)
+			}
+		})
+
+		if len(results) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	},
+}
+
+// diffOutput is a key that differs between two vaults. Status is missing
+// when the key is only in the first vault, extra when it is only in the
+// second and changed otherwise, with the names of the changed fields.
+type diffOutput struct {
+	Key      string   `json:"key" yaml:"key"`
+	Status   string   `json:"status" yaml:"status"`
+	Changes  []string `json:"changes,omitempty" yaml:"changes,omitempty"`
+	FromHash string   `json:"from_hash,omitempty" yaml:"from_hash,omitempty"`
+	ToHash   string   `json:"to_hash,omitempty" yaml:"to_hash,omitempty"`
+}
+
+// diffSecrets compares the records of two vaults and returns the keys
+// that differ, sorted. The fingerprints of the secrets are included when
+// fingerprint is not nil.
+func diffSecrets(from, to map[string]*vault.SecretRecord, fingerprint func(secret string) string) []diffOutput {
+	keys := map[string]bool{}
+	for key := range from {
+		keys[key] = true
+	}
+	for key := range to {
+		keys[key] = true
+	}
+
+	results := []diffOutput{}
+	for key := range keys {
+		a, b := from[key], to[key]
+		r := diffOutput{Key: key}
+		switch {
+		case b == nil:
+			r.Status = "missing"
+		case a == nil:
+			r.Status = "extra"
+		default:
+			r.Status = "changed"
+			r.Changes = recordChanges(a, b)
+			if len(r.Changes) == 0 {
+				continue
+			}
+		}
+
+		if fingerprint != nil {
+			if a != nil {
+				r.FromHash = fingerprint(a.Secret)
+			}
+			if b != nil {
+				r.ToHash = fingerprint(b.Secret)
+			}
+		}
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+
+	return results
+}
+
+// recordChanges returns the names of the fields that differ between the
+// records a and b, ignoring dates, versions and history.
+func recordChanges(a, b *vault.SecretRecord) []string {
+	changes := []string{}
+	if a.Secret != b.Secret {
+		changes = append(changes, "secret")
+	}
+	if a.Enabled != b.Enabled {
+		changes = append(changes, "enabled")
+	}
+	if (a.ExpiresAt == nil) != (b.ExpiresAt == nil) || (a.ExpiresAt != nil && !a.ExpiresAt.Equal(*b.ExpiresAt)) {
+		changes = append(changes, "expires_at")
+	}
+	if len(a.Tags) != len(b.Tags) || (len(a.Tags) > 0 && !reflect.DeepEqual(a.Tags, b.Tags)) {
+		changes = append(changes, "tags")
+	}
+	if !reflect.DeepEqual(a.Generator, b.Generator) {
+		changes = append(changes, "generator")
+	}
+
+	return changes
+}
+
+// formatHashes formats the fingerprints of r for the text output.
+func formatHashes(r diffOutput) string {
+	switch {
+	case r.FromHash != "" && r.ToHash != "" && r.FromHash != r.ToHash:
+		return " (" + r.FromHash + " -> " + r.ToHash + ")"
+	case r.FromHash != "":
+		return " (" + r.FromHash + ")"
+	case r.ToHash != "":
+		return " (" + r.ToHash + ")"
+	}
+
+	return ""
+}
+
+// loadSecrets reads the records of the vault named by spec, a path, URI
+// or registered name, or git:REV[:PATH] for a version committed to git.
+func loadSecrets(cmd *cobra.Command, spec string) (*vault.Snapshot, error) {
+	rest, ok := strings.CutPrefix(spec, "git:")
+	if !ok {
+		v, err := openNamedVault(spec)
+		if err != nil {
+			return nil, err
+		}
+		return v.Snapshot()
+	}
+
+	rev, name, _ := strings.Cut(rest, ":")
+	if rev == "" {
+		return nil, fmt.Errorf("expected git:REV or git:REV:PATH")
+	}
+	if name == "" {
+		name = getVaultName(cmd)
+	}
+
+	path, err := getFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := gitShow(rev, path)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := getBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return vault.Decode(path, data, vault.WithBackend(backend))
+}
+
+func init() {
+	diffCmd.Flags().StringP("match", "m", "", "Only compare secrets whose key matches the glob pattern")
+	diffCmd.Flags().Bool("hashes", false, "Show a short fingerprint of the secrets instead of only reporting changes")
+	rootCmd.AddCommand(diffCmd)
+}
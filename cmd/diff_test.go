@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+func TestRecordChanges(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	prod := "prod"
+
+	base := func() *vault.SecretRecord {
+		exp := expires
+		return &vault.SecretRecord{
+			Secret:    "s",
+			Enabled:   true,
+			ExpiresAt: &exp,
+			Tags:      map[string]*string{"env": &prod},
+			CreatedAt: created,
+			UpdatedAt: created,
+			Version:   1,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(r *vault.SecretRecord)
+		want   []string
+	}{
+		{"same", func(r *vault.SecretRecord) {}, []string{}},
+		{"secret", func(r *vault.SecretRecord) { r.Secret = "t" }, []string{"secret"}},
+		{"enabled", func(r *vault.SecretRecord) { r.Enabled = false }, []string{"enabled"}},
+		{"expires removed", func(r *vault.SecretRecord) { r.ExpiresAt = nil }, []string{"expires_at"}},
+		{"expires moved", func(r *vault.SecretRecord) { e := expires.Add(time.Hour); r.ExpiresAt = &e }, []string{"expires_at"}},
+		{"expires in another zone", func(r *vault.SecretRecord) {
+			e := expires.In(time.FixedZone("x", 3600))
+			r.ExpiresAt = &e
+		}, []string{}},
+		{"tag value", func(r *vault.SecretRecord) { dev := "dev"; r.Tags["env"] = &dev }, []string{"tags"}},
+		{"tags removed", func(r *vault.SecretRecord) { r.Tags = nil }, []string{"tags"}},
+		{"generator", func(r *vault.SecretRecord) { r.Generator = &vault.Generator{Size: 32} }, []string{"generator"}},
+		{"created and updated", func(r *vault.SecretRecord) {
+			r.CreatedAt = created.Add(time.Hour)
+			r.UpdatedAt = created.Add(2 * time.Hour)
+		}, []string{}},
+		{"version and history", func(r *vault.SecretRecord) {
+			r.Version = 2
+			r.ChangedBy = "ci"
+			r.Change = "rotate"
+			r.History = []vault.Version{{Version: 1, Secret: "old"}}
+			r.Previous = &vault.PreviousSecret{}
+		}, []string{}},
+		{"several", func(r *vault.SecretRecord) {
+			r.Secret = "t"
+			r.Enabled = false
+			r.Version = 3
+		}, []string{"secret", "enabled"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := base()
+			tt.change(b)
+			if got := recordChanges(base(), b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recordChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	empty := &vault.SecretRecord{Tags: map[string]*string{}}
+	if got := recordChanges(&vault.SecretRecord{}, empty); len(got) != 0 {
+		t.Errorf("recordChanges() of nil and empty tags = %q, want none", got)
+	}
+}
+
+func TestDiffSecrets(t *testing.T) {
+	from := map[string]*vault.SecretRecord{
+		"b":       {Secret: "1", Enabled: true},
+		"a":       {Secret: "2", Enabled: true},
+		"same":    {Secret: "3", Enabled: true, Version: 1},
+		"changed": {Secret: "4", Enabled: true},
+	}
+	to := map[string]*vault.SecretRecord{
+		"c":       {Secret: "5", Enabled: true},
+		"same":    {Secret: "3", Enabled: true, Version: 7},
+		"changed": {Secret: "6", Enabled: true},
+	}
+
+	got := diffSecrets(from, to, nil)
+	want := []diffOutput{
+		{Key: "a", Status: "missing"},
+		{Key: "b", Status: "missing"},
+		{Key: "c", Status: "extra"},
+		{Key: "changed", Status: "changed", Changes: []string{"secret"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSecrets() = %+v, want %+v", got, want)
+	}
+
+	hash := func(secret string) string { return "h" + secret }
+	got = diffSecrets(from, to, hash)
+	want = []diffOutput{
+		{Key: "a", Status: "missing", FromHash: "h2"},
+		{Key: "b", Status: "missing", FromHash: "h1"},
+		{Key: "c", Status: "extra", ToHash: "h5"},
+		{Key: "changed", Status: "changed", Changes: []string{"secret"}, FromHash: "h4", ToHash: "h6"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSecrets() with fingerprints = %+v, want %+v", got, want)
+	}
+
+	if got := diffSecrets(nil, nil, nil); len(got) != 0 {
+		t.Errorf("diffSecrets() of empty vaults = %+v, want none", got)
+	}
+}
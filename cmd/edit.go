@@ -1,48 +1,127 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 
-	"github.com/fatih/color"
+	"github.com/hyprxlabs/go/cmdargs"
 	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xsops/vault"
 	"github.com/spf13/cobra"
 )
 
 var editCmd = &cobra.Command{
 	Use:   "edit",
 	Short: "Edit a secret in the secrets database",
-	Long:  `Edit a secret in the secrets database using its URI.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Edit a secret in the secrets database using its URI.
+
+The vault is decrypted to a temporary file that only the current user can
+read, which is opened with the editor from XSOPS_EDITOR, the editor key of
+config.toml, SOPS_EDITOR or EDITOR, in that order. When the
+editor exits, the changes are encrypted back into the vault with the same
+keys and the temporary file is removed.
 
-		vault, _ := cmd.Flags().GetString("vault")
+If the vault is changed by another process while the editor is open, the
+changes are not saved instead of overwriting the other change.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		editor, _ := getSetting(getVaultName(cmd), "editor")
 		useCode, _ := cmd.Flags().GetBool("use-code")
 		if useCode {
-			os.Setenv("SOPS_EDITOR", "code --wait --new-window --disable-workspace-trust --disable-extensions --disable-telemetry")
+			editor = "code --wait --new-window --disable-workspace-trust --disable-extensions --disable-telemetry"
+		}
+
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
 		}
 
-		uriString := vault
-		filePath, err := getFilePath(uriString)
+		plaintext, rev, err := v.Decrypt()
 		if err != nil {
-			color.Red("[ERROR]: Error getting file path: %v", err)
+			errorf("Error decrypting vault: %v", err)
+			os.Exit(1)
+		}
+
+		tmpDir, err := os.MkdirTemp("", "xsops-edit-")
+		if err != nil {
+			errorf("Error creating temporary directory: %v", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		tmpFile := filepath.Join(tmpDir, filepath.Base(v.Path()))
+		if err := os.WriteFile(tmpFile, plaintext, 0600); err != nil {
+			errorf("Error writing temporary file: %v", err)
+			os.RemoveAll(tmpDir)
 			os.Exit(1)
 		}
 
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			color.Red("[ERROR]: The specified vault file does not exist: %s", filePath)
+		code := editFile(tmpFile, editor)
+		if code != 0 {
+			os.RemoveAll(tmpDir)
+			os.Exit(code)
+		}
+
+		edited, err := os.ReadFile(tmpFile)
+		if err != nil {
+			errorf("Error reading temporary file: %v", err)
+			os.RemoveAll(tmpDir)
 			os.Exit(1)
 		}
 
-		dir := filepath.Dir(filePath)
+		if bytes.Equal(edited, plaintext) {
+			os.RemoveAll(tmpDir)
+			os.Exit(0)
+		}
+
+		if err := v.Replace(edited, rev); err != nil {
+			if errors.Is(err, vault.ErrConflict) {
+				errorf("The vault was changed by another process while it was being edited, the changes were not saved.")
+				os.RemoveAll(tmpDir)
+				os.Exit(1)
+			}
+			errorf("Error saving vault: %v", err)
+			os.RemoveAll(tmpDir)
+			os.Exit(1)
+		}
 
-		cmd0 := exec.New("sops", filePath)
-		cmd0.Dir = dir
-		o, _ := cmd0.Run()
-		os.Exit(o.Code)
-		// Implement the edit logic here
+		os.RemoveAll(tmpDir)
+		os.Exit(0)
 	},
 }
 
+// editFile opens path in editor, or the editor from SOPS_EDITOR or EDITOR
+// when it is empty, and returns the exit code of the editor.
+func editFile(path string, editor string) int {
+	if editor == "" {
+		editor = os.Getenv("SOPS_EDITOR")
+	}
+
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	args := cmdargs.Split(editor).ToArray()
+	args = append(args, path)
+	o, err := exec.New(args[0], args[1:]...).Run()
+	if err != nil {
+		errorf("Error running editor: %v", err)
+		return 1
+	}
+
+	return o.Code
+}
+
 func init() {
 	editCmd.Flags().Bool("use-code", false, "Use vs code editor for editing")
 	rootCmd.AddCommand(editCmd)
@@ -1,15 +1,16 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
+	"text/tabwriter"
 
-	"github.com/fatih/color"
-	"github.com/hyprxlabs/go/exec"
-	"github.com/hyprxlabs/go/secrets"
+	"github.com/hyprxlabs/xsops/manifest"
+	"github.com/hyprxlabs/xsops/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -28,153 +29,222 @@ new secret is returned.
 If needed, use the --trim flag to trim whitespace from the secret value and not print as
 a new line.
 
+With --manifest every secret declared in xsops.yaml with a generate section is
+created in the vault of each environment when it is missing. Secrets without a
+generator are reported as manual. Generated secrets are not printed.
+
+The defaults of --size, --chars and --symbols can be set with the ensure.size,
+ensure.chars and ensure.symbols keys of config.toml; an empty ensure.symbols
+generates secrets without symbols.
+
 Output for anything other than the secret is disabled by default, use the --debug flag
 to enable it to triage issues.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if cmd.Flags().Changed("manifest") {
+			ensureManifest(cmd)
+			return
+		}
+
 		if len(args) < 1 {
-			color.Red("[ERROR]: You must provide the KEY to get a secret.")
-			color.Yellow("Usage: xsops -v <vault> get [KEY]")
+			errorf("You must provide the KEY to get a secret.")
+			usagef("Usage: xsops -v <vault> get [KEY]")
 			os.Exit(1)
 		}
 
-		vault, _ := cmd.Flags().GetString("vault")
 		debug, _ := cmd.Flags().GetBool("debug")
-
-		uriString := vault
 		key := args[0]
 
-		filePath, err := getFilePath(uriString)
+		v, err := openVault(cmd)
 		if err != nil {
 			if debug {
-				color.Red("[ERROR]: Error getting file path: %v", err)
+				errorf("Error opening vault: %v", err)
 			}
 			os.Exit(1)
 		}
 
-		dir := filepath.Dir(filePath)
+		g, err := configGenerator(getVaultName(cmd))
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+		applyGeneratorFlags(cmd, g)
 
-		cmd0 := exec.New("sops", "decrypt", "--extract", "[\""+key+"\"]", filePath)
-		cmd0.Dir = dir
+		secretRecord, created, err := v.Ensure(key, g)
+		if err != nil {
+			if debug {
+				errorf("Error ensuring secret: %v", err)
+			}
+			os.Exit(1)
+		}
 
-		res, _ := cmd0.Output()
+		trimit, _ := cmd.Flags().GetBool("trim")
+		if trimit {
+			secretRecord.Secret = strings.TrimSpace(secretRecord.Secret)
+		}
 
-		if len(res.Stdout) > 0 && res.Code == 0 {
-			jsonRecord := string(res.Text())
-			secretRecord := &SecretRecord{}
-			err = json.Unmarshal([]byte(jsonRecord), secretRecord)
-			if err != nil {
-				if debug {
-					color.Red("[ERROR]: Error unmarshalling JSON: %v", err)
-				}
-				os.Exit(1)
-			}
-			trimit, _ := cmd.Flags().GetBool("trim")
+		result := ensureOutput{recordOutput: newRecordOutput(key, secretRecord, true), Created: created}
+		printOutput(cmd, result, func(w io.Writer) {
 			if trimit {
-				secretRecord.Secret = strings.TrimSpace(secretRecord.Secret)
-				os.Stdout.WriteString(secretRecord.Secret)
-				os.Exit(0)
+				io.WriteString(w, secretRecord.Secret)
+				return
 			}
+			io.WriteString(w, secretRecord.Secret+"\n")
+		}, func(w *tabwriter.Writer) {
+			writeRecordTable(w, []recordOutput{result.recordOutput})
+		})
+		os.Exit(0)
+	},
+}
 
-			os.Stdout.WriteString(secretRecord.Secret)
-			os.Stdout.Write([]byte("\n"))
-			os.Exit(0)
+// configGenerator returns the generator configured by the ensure.size,
+// ensure.chars and ensure.symbols keys of config.toml for the vault named
+// vaultName.
+func configGenerator(vaultName string) (*vault.Generator, error) {
+	g := &vault.Generator{}
+	if value, ok := getSetting(vaultName, "ensure.size"); ok && value != "" {
+		n, err := strconv.ParseInt(value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ensure.size '%s' in config.toml, expected a number", value)
 		}
+		g.Size = int16(n)
+	}
 
-		builder := secrets.NewOptionsBuilder()
-		chars, _ := cmd.Flags().GetString("chars")
-		size, _ := cmd.Flags().GetInt16("size")
-		if size <= 0 {
-			size = 32 // Default size if not specified
-		}
+	g.Chars, _ = getSetting(vaultName, "ensure.chars")
+	if value, ok := getSetting(vaultName, "ensure.symbols"); ok {
+		g.Symbols = value
+		g.NoSymbols = value == ""
+	}
 
-		opts := []secrets.SetOption{}
-		if chars != "" {
-			opts = append(opts, secrets.WithChars(chars))
-		} else {
-			noUpper, _ := cmd.Flags().GetBool("no-upper")
-			noLower, _ := cmd.Flags().GetBool("no-lower")
-			noDigits, _ := cmd.Flags().GetBool("no-digits")
-			noSymbols, _ := cmd.Flags().GetBool("no-symbols")
-			symbols, _ := cmd.Flags().GetString("symbols")
-
-			if !noUpper {
-				opts = append(opts, secrets.WithUpper(!noUpper))
-			}
-			if !noLower {
-				opts = append(opts, secrets.WithLower(!noLower))
-			}
-			if !noDigits {
-				opts = append(opts, secrets.WithDigits(!noDigits))
-			}
-			if noSymbols {
-				opts = append(opts, secrets.WithNoSymbols())
-			} else if symbols != "" {
-				opts = append(opts, secrets.WithSymbols(symbols))
-			} else if symbols == "" {
-				opts = append(opts, secrets.WithSymbols("_-@#^~`|=+{}[]"))
-			}
-		}
+	return g, nil
+}
 
-		masker := builder.Build()
-		masker.Size = size
-		secretValue, err := secrets.Generate(size, opts...)
-		if secretValue == "" {
-			color.Red("[ERROR]: Failed to generate secret value.")
-			os.Exit(1)
-		}
+// addGeneratorFlags adds the flags that describe a generated secret to
+// cmd.
+func addGeneratorFlags(cmd *cobra.Command) {
+	cmd.Flags().Int16P("size", "s", 0, "Size of the generated secret")
+	cmd.Flags().BoolP("no-upper", "U", false, "Do not include uppercase letters in the secret")
+	cmd.Flags().BoolP("no-lower", "L", false, "Do not include lowercase letters in the secret")
+	cmd.Flags().BoolP("no-digits", "D", false, "Do not include numbers in the secret")
+	cmd.Flags().BoolP("no-symbols", "S", false, "Do not include symbols in the secret")
+	cmd.Flags().String("symbols", vault.DefaultSymbols, "Custom symbols to include in the secret")
+	cmd.Flags().StringP("chars", "c", "", "Custom characters to include in the secret")
+}
 
+// applyGeneratorFlags applies the generator flags given on the command
+// line of cmd to g.
+func applyGeneratorFlags(cmd *cobra.Command, g *vault.Generator) {
+	flags := cmd.Flags()
+	if flags.Changed("size") {
+		g.Size, _ = flags.GetInt16("size")
+	}
+	if flags.Changed("chars") {
+		g.Chars, _ = flags.GetString("chars")
+	}
+	if flags.Changed("symbols") {
+		g.Symbols, _ = flags.GetString("symbols")
+		g.NoSymbols = false
+	}
+	if flags.Changed("no-upper") {
+		g.NoUpper, _ = flags.GetBool("no-upper")
+	}
+	if flags.Changed("no-lower") {
+		g.NoLower, _ = flags.GetBool("no-lower")
+	}
+	if flags.Changed("no-digits") {
+		g.NoDigits, _ = flags.GetBool("no-digits")
+	}
+	if flags.Changed("no-symbols") {
+		g.NoSymbols, _ = flags.GetBool("no-symbols")
+	}
+}
+
+// ensureManifest creates the missing secrets of the manifest that have a
+// generator in the vault of every environment selected with --env.
+func ensureManifest(cmd *cobra.Command) {
+	m := loadManifest(cmd)
+	results := []ensureManifestOutput{}
+	for _, t := range manifestTargets(cmd, m) {
+		v, err := openNamedVault(t.Vault)
 		if err != nil {
-			if debug {
-				color.Red("[ERROR]: Error generating secret: %v", err)
+			if errors.Is(err, vault.ErrVaultNotFound) {
+				errorf("The vault %s of environment '%s' does not exist, create it with \"xsops -v %s init\".", t.Vault, displayEnv(t.Env), t.Vault)
+				os.Exit(1)
 			}
+			errorf("Error opening vault %s: %v", t.Vault, err)
 			os.Exit(1)
 		}
 
-		newSecretRecord := &SecretRecord{
-			Secret:    secretValue,
-			CreatedAt: time.Now().UTC(),
-			Enabled:   true,
-		}
-
-		jsonBytes, err := json.Marshal(newSecretRecord)
+		records, err := v.All()
 		if err != nil {
-			if debug {
-				color.Red("[ERROR]: Error marshalling JSON: %v", err)
-			}
+			errorf("Error reading vault %s: %v", t.Vault, err)
 			os.Exit(1)
 		}
 
-		cmd0 = exec.New("sops", "set", filePath, "[\""+key+"\"]", string(jsonBytes))
-		cmd0.Dir = dir
-		res, err = cmd0.Output()
-		if err != nil {
-			if debug {
-				color.Red("[ERROR]: Error executing sops set command: %v", err)
-				color.Red("[DEBUG]: Command output: %s", res.Text())
+		for _, key := range m.Keys() {
+			s := m.Secrets[key]
+			result := ensureManifestOutput{Env: t.Env, Vault: t.Vault, Key: key}
+			if _, ok := records[key]; ok {
+				result.Status = "exists"
+				results = append(results, result)
+				continue
 			}
-			os.Exit(1)
+
+			if s.Generate == nil {
+				result.Status = "manual"
+				if s.IsRequired() {
+					warnf("%s is required in environment '%s' but has no generator, set it with \"xsops set\".", key, displayEnv(t.Env))
+				}
+				results = append(results, result)
+				continue
+			}
+
+			if _, _, err := v.Ensure(key, s.Generate); err != nil {
+				errorf("Error ensuring %s in %s: %v", key, t.Vault, err)
+				os.Exit(1)
+			}
+			result.Status = "created"
+			results = append(results, result)
 		}
+	}
 
-		trimit, _ := cmd.Flags().GetBool("trim")
-		if trimit {
-			os.Stdout.WriteString(strings.TrimSpace(secretValue))
-			os.Exit(0)
+	writeTable := func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ENV\tKEY\tSTATUS")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", displayEnv(r.Env), r.Key, r.Status)
 		}
+	}
+
+	printOutput(cmd, results, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		writeTable(tw)
+		tw.Flush()
+	}, writeTable)
+	os.Exit(0)
+}
 
-		os.Stdout.WriteString(secretValue + "\n")
-		os.Exit(res.Code)
-	},
+// ensureManifestOutput is the result of ensure --manifest for a secret.
+// Status is created, exists or manual for secrets without a generator.
+// Generated secrets are never printed.
+type ensureManifestOutput struct {
+	Env    string `json:"env,omitempty" yaml:"env,omitempty"`
+	Vault  string `json:"vault" yaml:"vault"`
+	Key    string `json:"key" yaml:"key"`
+	Status string `json:"status" yaml:"status"`
+}
+
+// ensureOutput is the result of ensure, created reports whether a new
+// secret was generated.
+type ensureOutput struct {
+	recordOutput `yaml:",inline"`
+	Created      bool `json:"created" yaml:"created"`
 }
 
 func init() {
 	rootCmd.AddCommand(ensureCmd)
-	ensureCmd.Flags().Int16P("size", "s", 0, "Size of the secret to ensure")
-	ensureCmd.Flags().BoolP("no-upper", "U", false, "Do not include uppercase letters in the secret")
-	ensureCmd.Flags().BoolP("no-lower", "L", false, "Do not include lowercase letters in the secret")
-	ensureCmd.Flags().BoolP("no-digits", "D", false, "Do not include numbers in the secret")
-	ensureCmd.Flags().BoolP("no-symbols", "S", false, "Do not include symbols in the secret")
-	ensureCmd.Flags().String("symbols", "_-@#^~`|=+{}[]", "Custom symbols to include in the secret")
+	addGeneratorFlags(ensureCmd)
 	ensureCmd.Flags().Bool("trim", false, "Trim whitespace from the secret value and not print as new line")
-	ensureCmd.Flags().StringP("chars", "c", "", "Custom characters to include in the secret")
+	ensureCmd.Flags().StringP("manifest", "m", "", "Create the missing generated secrets of a manifest, defaults to the closest "+manifest.FileName)
+	ensureCmd.Flags().Lookup("manifest").NoOptDefVal = manifest.FileName
+	ensureCmd.Flags().StringSlice("env", nil, "Environments of the manifest to ensure, defaults to all")
 }
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyprxlabs/xsops/internal/config"
+	"github.com/hyprxlabs/xsops/internal/query"
+	"github.com/hyprxlabs/xsops/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// Statuses reported by expiring.
+const (
+	expiryOK       = "ok"
+	expiryExpiring = "expiring"
+	expiryExpired  = "expired"
+	expiryMissing  = "no-expiry"
+	expiryError    = "error"
+)
+
+var expiringCmd = &cobra.Command{
+	Use:   "expiring",
+	Short: "List secrets that expire soon or have expired",
+	Long: `List the secrets that expire within the --within duration or have
+already expired, in the vault selected with --vault or in every registered
+vault with --all. With --missing secrets without an expiration time are
+listed as well. Disabled secrets are ignored.
+
+Durations are Go durations such as 36h, or a number of days or weeks such
+as 14d or 2w.
+
+The exit code is 1 when a secret is listed or a vault cannot be read, so
+the command can gate CI pipelines. Use --format junit to write a JUnit XML
+report with a test case per secret for CI servers.
+
+Use --format ics to write an iCalendar feed with an event at the expiration
+time of every secret that has one, regardless of --within, and reminders
+--remind before it. Events name the key and the vault, never the secret.
+The exit code is only 1 when a vault cannot be read.`,
+	Example: `xsops expiring --within 14d
+xsops expiring --all --missing -o json
+xsops expiring --all --within 30d --format junit > expiring.xml
+xsops expiring --all --format ics --remind 14d,1d > expirations.ics`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		within, _ := cmd.Flags().GetString("within")
+		all, _ := cmd.Flags().GetBool("all")
+		missing, _ := cmd.Flags().GetBool("missing")
+		format, _ := cmd.Flags().GetString("format")
+		remind, _ := cmd.Flags().GetStringSlice("remind")
+
+		d, err := query.ParseDuration(within)
+		if err != nil {
+			errorf("Invalid --within duration '%s': %v", within, err)
+			os.Exit(1)
+		}
+
+		format = strings.ToLower(format)
+		switch format {
+		case "", "junit", "ics":
+		default:
+			errorf("Unknown format '%s', expected junit or ics", format)
+			os.Exit(1)
+		}
+
+		reminders := []time.Duration{}
+		for _, r := range remind {
+			d, err := query.ParseDuration(r)
+			if err != nil {
+				errorf("Invalid --remind duration '%s': %v", r, err)
+				os.Exit(1)
+			}
+			reminders = append(reminders, d)
+		}
+
+		vaults := []string{getVaultName(cmd)}
+		if all {
+			registry, err := config.LoadRegistry()
+			if err != nil {
+				errorf("Error reading the vault registry: %v", err)
+				os.Exit(1)
+			}
+
+			vaults = vaults[:0]
+			for _, entry := range registry.Entries() {
+				vaults = append(vaults, entry.Name)
+			}
+
+			if len(vaults) == 0 {
+				warnf("No vaults are registered, add them with \"xsops vault add\".")
+			}
+		}
+
+		now := time.Now()
+		checked := checkExpiring(vaults, d, missing, now)
+		if format == "ics" {
+			failed := false
+			for _, r := range checked {
+				failed = failed || r.Status == expiryError
+			}
+
+			if err := report.WriteICS(os.Stdout, "xsops expirations", expiringEvents(checked, reminders), now); err != nil {
+				errorf("Error writing the calendar: %v", err)
+				os.Exit(1)
+			}
+
+			if failed {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		results := []expiringOutput{}
+		for _, r := range checked {
+			if r.Status != expiryOK {
+				results = append(results, r)
+			}
+		}
+
+		if format == "junit" {
+			if err := report.WriteJUnit(os.Stdout, expiringJUnit(checked, within)); err != nil {
+				errorf("Error writing the report: %v", err)
+				os.Exit(1)
+			}
+		} else {
+			writeTable := func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "VAULT\tKEY\tSTATUS\tEXPIRES\tEXPIRES IN")
+				for _, r := range results {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Vault, orDash(r.Key), r.Status, formatTime(r.ExpiresAt), formatRemaining(r.ExpiresAt))
+				}
+			}
+
+			printOutput(cmd, results, func(w io.Writer) {
+				if len(results) == 0 {
+					infof("No secrets expire within %s.", within)
+					return
+				}
+
+				tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+				writeTable(tw)
+				tw.Flush()
+			}, writeTable)
+		}
+
+		if len(results) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	},
+}
+
+// expiringOutput is the expiration state of a secret. Key is empty for
+// vaults that cannot be read, with the reason in Error.
+type expiringOutput struct {
+	Vault     string     `json:"vault" yaml:"vault"`
+	Key       string     `json:"key,omitempty" yaml:"key,omitempty"`
+	Status    string     `json:"status" yaml:"status"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Error     string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// checkExpiring returns the expiration state of every enabled secret in
+// the vaults, sorted by vault and expiration time. Secrets expiring
+// within d of now are expiring and, when missing is true, secrets
+// without an expiration time are reported as no-expiry.
+func checkExpiring(vaults []string, d time.Duration, missing bool, now time.Time) []expiringOutput {
+	results := []expiringOutput{}
+	for _, name := range vaults {
+		v, err := openNamedVault(name)
+		if err != nil {
+			warnf("Error opening vault %s: %v", name, err)
+			results = append(results, expiringOutput{Vault: name, Status: expiryError, Error: err.Error()})
+			continue
+		}
+
+		records, err := v.All()
+		if err != nil {
+			warnf("Error reading vault %s: %v", name, err)
+			results = append(results, expiringOutput{Vault: name, Status: expiryError, Error: err.Error()})
+			continue
+		}
+
+		start := len(results)
+		for key, record := range records {
+			if !record.Enabled {
+				continue
+			}
+
+			r := expiringOutput{Vault: name, Key: key, Status: expiryOK, ExpiresAt: record.ExpiresAt}
+			switch {
+			case record.IsExpired(now):
+				r.Status = expiryExpired
+			case record.ExpiresAt != nil && record.ExpiresAt.Before(now.Add(d)):
+				r.Status = expiryExpiring
+			case record.ExpiresAt == nil && missing:
+				r.Status = expiryMissing
+			}
+			results = append(results, r)
+		}
+
+		sortExpiring(results[start:])
+	}
+
+	return results
+}
+
+// sortExpiring sorts results by expiration time, soonest first, with
+// secrets without an expiration time last.
+func sortExpiring(results []expiringOutput) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].ExpiresAt, results[j].ExpiresAt
+		switch {
+		case a == nil || b == nil:
+			if (a == nil) != (b == nil) {
+				return b == nil
+			}
+			return results[i].Key < results[j].Key
+		case a.Equal(*b):
+			return results[i].Key < results[j].Key
+		default:
+			return a.Before(*b)
+		}
+	})
+}
+
+// expiringJUnit converts results into a JUnit report with a test suite
+// per vault and a test case per secret.
+func expiringJUnit(results []expiringOutput, within string) *report.TestSuites {
+	s := &report.TestSuites{Name: "xsops expiring"}
+	suites := map[string]int{}
+	for _, r := range results {
+		i, ok := suites[r.Vault]
+		if !ok {
+			i = len(s.Suites)
+			suites[r.Vault] = i
+			s.Suites = append(s.Suites, report.TestSuite{Name: r.Vault})
+		}
+
+		c := report.TestCase{Name: r.Key, ClassName: r.Vault}
+		switch r.Status {
+		case expiryError:
+			c.Name = r.Vault
+			c.Error = &report.Problem{Message: "cannot read vault", Type: r.Status, Text: r.Error}
+		case expiryExpired:
+			c.Failure = &report.Problem{Message: "expired at " + formatTime(r.ExpiresAt), Type: r.Status}
+		case expiryExpiring:
+			c.Failure = &report.Problem{
+				Message: fmt.Sprintf("expires at %s, within %s", formatTime(r.ExpiresAt), within),
+				Type:    r.Status,
+			}
+		case expiryMissing:
+			c.Failure = &report.Problem{Message: "has no expiration time", Type: r.Status}
+		}
+		s.Suites[i].Cases = append(s.Suites[i].Cases, c)
+	}
+
+	return s
+}
+
+// expiringEvents converts the results with an expiration time into
+// calendar events with the reminders.
+func expiringEvents(results []expiringOutput, reminders []time.Duration) []report.Event {
+	events := []report.Event{}
+	for _, r := range results {
+		if r.ExpiresAt == nil {
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(r.Vault + "\x00" + r.Key))
+		events = append(events, report.Event{
+			UID:     hex.EncodeToString(sum[:16]) + "@xsops",
+			Summary: fmt.Sprintf("Secret %s in %s expires", r.Key, r.Vault),
+			Description: fmt.Sprintf("The secret %s in the vault %s expires at %s. Rotate it with \"xsops -v %s rotate %s\".",
+				r.Key, r.Vault, r.ExpiresAt.UTC().Format(time.RFC3339), r.Vault, r.Key),
+			Start:     *r.ExpiresAt,
+			Reminders: reminders,
+		})
+	}
+
+	return events
+}
+
+// formatRemaining formats the time left until t in days and hours, or
+// "-" when t is nil.
+func formatRemaining(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	d := time.Until(*t)
+	if d <= 0 {
+		return "expired"
+	}
+
+	days, hours := int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour)
+	if days == 0 {
+		return fmt.Sprintf("%dh", max(hours, 1))
+	}
+
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
+
+func init() {
+	expiringCmd.Flags().String("within", "14d", "Report secrets expiring within this duration, such as 14d, 2w or 36h")
+	expiringCmd.Flags().BoolP("all", "a", false, "Check every registered vault instead of the selected vault")
+	expiringCmd.Flags().Bool("missing", false, "Also report secrets without an expiration time")
+	expiringCmd.Flags().StringP("format", "F", "", "Report format instead of --output: junit or ics")
+	expiringCmd.Flags().StringSlice("remind", []string{"7d", "1d"}, "How long before each expiration ics reminders go off")
+	rootCmd.AddCommand(expiringCmd)
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/hyprxlabs/xsops/internal/dotenv"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export secrets as dotenv, shell, json or yaml",
+	Long: `Export the secrets of a vault in another format.
+
+Supported formats for --format:
+- dotenv: KEY=value lines for .env files (default)
+- env-file: KEY=value lines for docker --env-file, multiline values are rejected
+- bash, sh, zsh: export KEY='value' statements
+- fish: set -gx KEY 'value' statements
+- powershell, pwsh: $env:KEY = 'value' statements
+- nushell, nu: $env.KEY = "value" statements
+- json: a flat json object
+- yaml: a flat yaml mapping
+
+Keys are converted to environment variable names the same way as the run
+command. For json and yaml the keys are kept as is unless --transform is set.
+
+Disabled and expired secrets are skipped with a warning unless
+--allow-disabled or --allow-expired is used. When --match names such a
+secret exactly, without wildcards, the export fails with exit code 3 for
+disabled or 4 for expired secrets.`,
+	Example: `xsops export > .env
+xsops -v default export --format bash --match "db-*"
+xsops export --format json --tag env=prod
+eval "$(xsops export --format bash)"
+xsops export --format powershell | Invoke-Expression`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		prefix, _ := cmd.Flags().GetString("prefix")
+		transform, _ := cmd.Flags().GetString("transform")
+		match, _ := cmd.Flags().GetString("match")
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		file, _ := cmd.Flags().GetString("file")
+
+		format = strings.ToLower(format)
+		if (format == "json" || format == "yaml") && !cmd.Flags().Changed("transform") {
+			transform = "none"
+		}
+
+		var g glob.Glob
+		if match != "" {
+			var err error
+			g, err = vault.CompileGlob(match)
+			if err != nil {
+				errorf("Invalid glob pattern %q: %v", match, err)
+				os.Exit(1)
+			}
+		}
+
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		records, err := v.All()
+		if err != nil {
+			errorf("Error reading secrets: %v", err)
+			os.Exit(1)
+		}
+
+		keys := make([]string, 0, len(records))
+		for key := range records {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		selected := map[string]*vault.SecretRecord{}
+		for _, key := range keys {
+			record := records[key]
+			if g != nil && !g.Match(key) {
+				continue
+			}
+
+			if matchTags(record, tags) {
+				selected[key] = record
+			}
+		}
+		selected = checkLifecycle(cmd, selected, namedKeys(match), time.Now())
+
+		exported := []string{}
+		for _, key := range keys {
+			if _, ok := selected[key]; ok {
+				exported = append(exported, key)
+			}
+		}
+
+		names, err := envNames(exported, prefix, transform)
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+
+		entries := make([]exportEntry, len(exported))
+		for i, key := range exported {
+			entries[i] = exportEntry{Name: names[i], Value: selected[key].Secret}
+		}
+
+		out, err := formatExport(format, entries)
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+
+		if file != "" {
+			if err := writePrivateFile(file, out); err != nil {
+				errorf("Error writing file: %v", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		os.Stdout.Write(out)
+		os.Exit(0)
+	},
+}
+
+// writePrivateFile replaces the file at path with a new file holding
+// data that only the user can read, whatever the mode of the file it
+// replaces. The file is written next to path and renamed into place so
+// that the secrets are never readable by others, even briefly.
+func writePrivateFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".xsops-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+type exportEntry struct {
+	Name  string
+	Value string
+}
+
+// matchTags reports whether record has every tag in filters. A filter
+// is either "name" which requires the tag to exist or "name=value"
+// which also requires the value to match.
+func matchTags(record *vault.SecretRecord, filters []string) bool {
+	for _, filter := range filters {
+		name, value, hasValue := strings.Cut(filter, "=")
+		tag, ok := record.Tags[name]
+		if !ok {
+			return false
+		}
+
+		if hasValue && (tag == nil || *tag != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatExport(format string, entries []exportEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "dotenv", "env", "":
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "%s=%s\n", e.Name, dotenv.Quote(e.Value))
+		}
+	case "env-file", "docker":
+		for _, e := range entries {
+			if strings.ContainsAny(e.Value, "\n\r") {
+				return nil, fmt.Errorf("the value of %s spans multiple lines which is not supported by the env-file format", e.Name)
+			}
+			fmt.Fprintf(&buf, "%s=%s\n", e.Name, e.Value)
+		}
+	case "bash", "sh", "zsh":
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "export %s=%s\n", e.Name, quotePosix(e.Value))
+		}
+	case "fish":
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "set -gx %s %s\n", e.Name, quoteFish(e.Value))
+		}
+	case "powershell", "pwsh":
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "$env:%s = %s\n", e.Name, quotePowerShell(e.Value))
+		}
+	case "nushell", "nu":
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "$env.%s = %s\n", e.Name, quoteNushell(e.Value))
+		}
+	case "json":
+		data := make(map[string]string, len(entries))
+		for _, e := range entries {
+			data[e.Name] = e.Value
+		}
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(out)
+		buf.WriteByte('\n')
+	case "yaml", "yml":
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, e := range entries {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.Name},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.Value})
+		}
+		if len(entries) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return nil, err
+		}
+		enc.Close()
+	default:
+		return nil, fmt.Errorf("unknown export format '%s'", format)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func quotePosix(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func quoteFish(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(value) + "'"
+}
+
+func quotePowerShell(value string) string {
+	// PowerShell also treats the typographic single quotes as quotes.
+	r := strings.NewReplacer(`'`, `''`, "‘", "‘‘", "’", "’’",
+		"‚", "‚‚", "‛", "‛‛")
+	return "'" + r.Replace(value) + "'"
+}
+
+func quoteNushell(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+func init() {
+	exportCmd.Flags().StringP("format", "F", "dotenv", "Output format: dotenv, env-file, bash, fish, powershell, nushell, json or yaml")
+	exportCmd.Flags().String("prefix", "", "Prefix to prepend to every name")
+	exportCmd.Flags().String("transform", "upper", "How keys are converted to names: upper, lower or none")
+	exportCmd.Flags().StringP("match", "m", "", "Only export secrets whose key matches the glob pattern")
+	exportCmd.Flags().StringArrayP("tag", "t", nil, "Only export secrets with the tag, as name or name=value (repeatable)")
+	addLifecycleFlags(exportCmd)
+	exportCmd.Flags().Bool("include-disabled", false, "Include disabled secrets")
+	exportCmd.Flags().Bool("include-expired", false, "Include expired secrets")
+	exportCmd.Flags().MarkDeprecated("include-disabled", "use --allow-disabled instead")
+	exportCmd.Flags().MarkDeprecated("include-expired", "use --allow-expired instead")
+	exportCmd.Flags().StringP("file", "f", "", "Write the output to a file with user only permissions instead of stdout")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hyprxlabs/xsops/internal/dotenv"
+)
+
+// exportValues are secrets that are hard to quote: quotes, shell
+// metacharacters, backslashes, line breaks and typographic quotes.
+var exportValues = []string{
+	"plain",
+	"it's",
+	"line1\nline2",
+	`$HOME "x" \ ;|&`,
+	"‘smart’",
+	"",
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		quote func(string) string
+		want  []string
+	}{
+		{"dotenv", dotenv.Quote, []string{
+			"plain",
+			`"it's"`,
+			`"line1\nline2"`,
+			`"\$HOME \"x\" \\ ;|&"`,
+			"'‘smart’'",
+			"''",
+		}},
+		{"posix", quotePosix, []string{
+			"'plain'",
+			`'it'\''s'`,
+			"'line1\nline2'",
+			`'$HOME "x" \ ;|&'`,
+			"'‘smart’'",
+			"''",
+		}},
+		{"fish", quoteFish, []string{
+			"'plain'",
+			`'it\'s'`,
+			"'line1\nline2'",
+			`'$HOME "x" \\ ;|&'`,
+			"'‘smart’'",
+			"''",
+		}},
+		{"powershell", quotePowerShell, []string{
+			"'plain'",
+			"'it''s'",
+			"'line1\nline2'",
+			`'$HOME "x" \ ;|&'`,
+			"'‘‘smart’’'",
+			"''",
+		}},
+		{"nushell", quoteNushell, []string{
+			`"plain"`,
+			`"it's"`,
+			`"line1\nline2"`,
+			`"$HOME \"x\" \\ ;|&"`,
+			`"‘smart’"`,
+			`""`,
+		}},
+	}
+
+	for _, tt := range tests {
+		for i, value := range exportValues {
+			if got := tt.quote(value); got != tt.want[i] {
+				t.Errorf("%s quote(%q) = %q, want %q", tt.name, value, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFormatExportDotenvRoundTrip(t *testing.T) {
+	entries := make([]exportEntry, len(exportValues))
+	for i, value := range exportValues {
+		entries[i] = exportEntry{Name: "K" + string(rune('A'+i)), Value: value}
+	}
+
+	out, err := formatExport("dotenv", entries)
+	if err != nil {
+		t.Fatalf("formatExport() error = %v", err)
+	}
+
+	parsed, err := dotenv.Parse(out)
+	if err != nil {
+		t.Fatalf("dotenv.Parse() error = %v\n%s", err, out)
+	}
+
+	if len(parsed) != len(entries) {
+		t.Fatalf("dotenv.Parse() = %d entries, want %d\n%s", len(parsed), len(entries), out)
+	}
+	for i, e := range parsed {
+		if e.Key != entries[i].Name || e.Value != entries[i].Value {
+			t.Errorf("dotenv.Parse()[%d] = %s=%q, want %s=%q", i, e.Key, e.Value, entries[i].Name, entries[i].Value)
+		}
+	}
+}
+
+func TestFormatExportPosixRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	for _, value := range exportValues {
+		out, err := formatExport("sh", []exportEntry{{Name: "XSOPS_TEST_VALUE", Value: value}})
+		if err != nil {
+			t.Fatalf("formatExport() error = %v", err)
+		}
+
+		got, err := exec.Command(sh, "-c", string(out)+`printf %s "$XSOPS_TEST_VALUE"`).Output()
+		if err != nil {
+			t.Fatalf("sh error = %v\n%s", err, out)
+		}
+
+		if string(got) != value {
+			t.Errorf("sh read %q, want %q", got, value)
+		}
+	}
+}
+
+func TestFormatExportEnvFile(t *testing.T) {
+	if _, err := formatExport("env-file", []exportEntry{{Name: "A", Value: "a\nb"}}); err == nil {
+		t.Error("formatExport() of a multiline env-file value should fail")
+	}
+}
+
+func TestWritePrivateFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("OLD=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writePrivateFile(path, []byte("NEW=2\n")); err != nil {
+		t.Fatalf("writePrivateFile() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "NEW=2\n" {
+		t.Errorf("contents = %q, want NEW=2", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("writePrivateFile() left %d files behind", len(entries)-1)
+	}
+}
@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/fatih/color"
-	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xsops/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -18,65 +18,100 @@ var getCmd = &cobra.Command{
 	
 If needed, use the --trim flag to trim whitespace from the secret value and not print as a new line.
 
+Use --previous to get the secret replaced by the last "xsops rotate" while its
+grace period lasts, or --version N to get a version listed by "xsops history".
+
+Disabled secrets exit with 3 and expired secrets with 4 unless
+--allow-disabled or --allow-expired is used.
+
 Output for anything other than the secret is disabled by default, use the 
---debug flag to enable it to triage issues.
+--debug flag to enable it to triage issues. Use --output table, json or yaml
+to print the secret along with its metadata.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) < 1 {
-			color.Red("[ERROR]: You must provide a key to get a secret.")
-			color.Yellow("Usage: xsops get [KEY]")
+			errorf("You must provide a key to get a secret.")
+			usagef("Usage: xsops get [KEY]")
 			os.Exit(1)
 		}
 
 		debug, _ := cmd.Flags().GetBool("debug")
-		vault, _ := cmd.Flags().GetString("vault")
-		uriString := vault
 		key := args[0]
 
-		filePath, err := getFilePath(uriString)
+		v, err := openVault(cmd)
 		if err != nil {
 			if debug {
-				color.Red("[ERROR]: Error getting file path: %v", err)
+				errorf("Error opening vault: %v", err)
 			}
 			os.Exit(1)
 		}
 
-		dir := filepath.Dir(filePath)
-		cmd0 := exec.New("sops", "decrypt", "--extract", "[\""+key+"\"]", filePath)
-		cmd0.Dir = dir
-
-		res, err := cmd0.Output()
+		secretRecord, err := v.Get(key)
 		if err != nil {
 			if debug {
-				color.Red("[ERROR]: Error executing sops command: %v", err)
+				errorf("Error getting secret: %v", err)
 			}
 			os.Exit(1)
 		}
 
-		jsonRecord := string(res.Text())
-		secretRecord := &SecretRecord{}
-		err = json.Unmarshal([]byte(jsonRecord), secretRecord)
-		if err != nil {
-			if debug {
-				color.Red("[ERROR]: Error unmarshalling JSON: %v", err)
+		checkLifecycle(cmd, map[string]*vault.SecretRecord{key: secretRecord}, namedKeys(key), time.Now())
+
+		previous, _ := cmd.Flags().GetBool("previous")
+		if cmd.Flags().Changed("version") {
+			if previous {
+				errorf("--version and --previous cannot be used together.")
+				os.Exit(1)
 			}
-			os.Exit(1)
+
+			n, _ := cmd.Flags().GetInt("version")
+			version, err := secretRecord.GetVersion(n)
+			if err != nil {
+				errorf("Secret '%s' has no version %d, see \"xsops history %s\".", key, n, key)
+				os.Exit(1)
+			}
+
+			secretRecord.Secret = version.Secret
+			secretRecord.Version = version.Version
+		}
+
+		if previous {
+			if secretRecord.Previous == nil {
+				errorf("Secret '%s' has not been rotated.", key)
+				os.Exit(1)
+			}
+
+			if secretRecord.Previous.IsExpired(time.Now()) {
+				errorf("The grace period of the previous secret of '%s' ended at %s.", key, formatTime(secretRecord.Previous.ExpiresAt))
+				os.Exit(1)
+			}
+
+			secretRecord.Secret = secretRecord.Previous.Secret
 		}
 
 		trimit, _ := cmd.Flags().GetBool("trim")
 		if trimit {
 			secretRecord.Secret = strings.TrimSpace(secretRecord.Secret)
-			os.Stdout.WriteString(secretRecord.Secret)
-			os.Exit(0)
 		}
 
-		os.Stdout.WriteString(secretRecord.Secret + "\n")
+		result := newRecordOutput(key, secretRecord, true)
+		printOutput(cmd, result, func(w io.Writer) {
+			if trimit {
+				io.WriteString(w, secretRecord.Secret)
+				return
+			}
+			io.WriteString(w, secretRecord.Secret+"\n")
+		}, func(w *tabwriter.Writer) {
+			writeRecordTable(w, []recordOutput{result})
+		})
 		os.Exit(0)
 	},
 }
 
 func init() {
 	getCmd.Flags().Bool("trim", false, "Trim whitespace from the secret value and not print as new line")
+	getCmd.Flags().Bool("previous", false, "Get the secret replaced by the last rotation")
+	getCmd.Flags().Int("version", 0, "Get a prior version of the secret")
+	addLifecycleFlags(getCmd)
 	rootCmd.AddCommand(getCmd)
 }
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+// defaultGitPattern selects the vault files in .gitattributes.
+const defaultGitPattern = "*.secrets.json"
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Integrate vault files with git",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var gitInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Configure git to merge and diff vault files with xsops",
+	Long: `Configure the git repository in the current directory to merge vault
+files with "xsops merge-driver", so keys added or changed on different
+branches merge cleanly instead of conflicting on the sops ciphertext, and
+to diff them with "xsops git textconv", so git diff and git log -p show
+which keys changed instead of ciphertext.
+
+The vault files, *.secrets.json unless --pattern is given, are marked with
+merge=xsops and diff=xsops in the .gitattributes file at the root of the repository, which
+should be committed. The drivers themselves are declared in the git config of the
+repository, or the global git config with --global, which every developer
+has to run once.`,
+	Example: `xsops git install
+xsops git install --pattern "secrets/*.json" --global`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		patterns, _ := cmd.Flags().GetStringSlice("pattern")
+		global, _ := cmd.Flags().GetBool("global")
+
+		root, err := runGit("", "rev-parse", "--show-toplevel")
+		if err != nil {
+			errorf("Error finding the git repository: %v", err)
+			os.Exit(1)
+		}
+
+		scope := "--local"
+		if global {
+			scope = "--global"
+		}
+
+		config := [][2]string{
+			{"merge.xsops.name", "xsops vault merge"},
+			{"merge.xsops.driver", "xsops merge-driver %O %A %B %P"},
+			{"diff.xsops.textconv", "xsops git textconv"},
+		}
+		for _, kv := range config {
+			if _, err := runGit(root, "config", scope, kv[0], kv[1]); err != nil {
+				errorf("Error setting %s: %v", kv[0], err)
+				os.Exit(1)
+			}
+		}
+
+		path := filepath.Join(root, ".gitattributes")
+		added, err := addGitAttributes(path, patterns, "merge=xsops", "diff=xsops")
+		if err != nil {
+			errorf("Error updating %s: %v", path, err)
+			os.Exit(1)
+		}
+
+		for _, line := range added {
+			infof("Added '%s' to %s.", line, path)
+		}
+		infof("Vault files are merged with xsops merge-driver and diffed with xsops git textconv.")
+		os.Exit(0)
+	},
+}
+
+var gitTextconvCmd = &cobra.Command{
+	Use:   "textconv FILE",
+	Short: "Print a redacted listing of a vault file, for use as a git textconv",
+	Long: `Decrypt the vault FILE and print its keys sorted, each followed by a
+fingerprint of the secret and its metadata: enabled, expiry, tags, version
+and update time. Secrets are never printed, so the output is safe to show
+in git diff and code review. Fingerprints are an HMAC keyed by a random key
+stored in the vault as a field named secret, which is encrypted whenever
+the secrets are. Checking a guessed value against a fingerprint needs that
+key, so only those who can decrypt the vault can do it. Vaults created
+before fingerprint keys, or that stored the key in plaintext, show - until
+their next change.
+
+Use "xsops git install" to configure git to run this command for vault
+files.`,
+	Example: `xsops git textconv xsops.secrets.json
+git diff HEAD~1 -- xsops.secrets.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			errorf("Error reading %s: %v", args[0], err)
+			os.Exit(1)
+		}
+
+		if len(data) == 0 {
+			os.Exit(0)
+		}
+
+		backend, err := getBackend(args[0])
+		if err != nil {
+			errorf("Error creating backend: %v", err)
+			os.Exit(1)
+		}
+
+		snapshot, err := vault.Decode(args[0], data, vault.WithBackend(backend))
+		if err != nil {
+			errorf("Error decrypting %s: %v", args[0], err)
+			os.Exit(1)
+		}
+
+		writeTextconv(os.Stdout, snapshot)
+		os.Exit(0)
+	},
+}
+
+// writeTextconv writes the records of s sorted by key with one line per
+// field, so that a change to a field shows as a change to its line.
+// Times are in UTC to be the same on every machine.
+func writeTextconv(w io.Writer, s *vault.Snapshot) {
+	records := s.Records
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	utc := func(t *time.Time) string {
+		if t == nil || t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	for _, key := range keys {
+		r := records[key]
+		updated := r.UpdatedAt
+		if updated.IsZero() {
+			updated = r.CreatedAt
+		}
+
+		fmt.Fprintf(w, "%s\n", key)
+		fmt.Fprintf(w, "  secret: %s\n", orDash(s.Fingerprint(r.Secret)))
+		fmt.Fprintf(w, "  enabled: %t\n", r.Enabled)
+		fmt.Fprintf(w, "  expires_at: %s\n", utc(r.ExpiresAt))
+		fmt.Fprintf(w, "  tags: %s\n", formatTags(r.Tags))
+		fmt.Fprintf(w, "  version: %d\n", r.CurrentVersion())
+		fmt.Fprintf(w, "  updated_at: %s\n", utc(&updated))
+	}
+}
+
+// addGitAttributes adds attrs to the lines of the .gitattributes file at
+// path for each pattern, appending a line for patterns that have none,
+// and returns the lines it changed.
+func addGitAttributes(path string, patterns []string, attrs ...string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	lines := []string{}
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+
+	changed := []string{}
+	for _, pattern := range patterns {
+		found := false
+		for i, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || fields[0] != pattern {
+				continue
+			}
+
+			found = true
+			missing := false
+			for _, attr := range attrs {
+				if !slices.Contains(fields[1:], attr) {
+					fields = append(fields, attr)
+					missing = true
+				}
+			}
+			if missing {
+				lines[i] = strings.Join(fields, " ")
+				changed = append(changed, lines[i])
+			}
+		}
+
+		if !found {
+			line := strings.Join(append([]string{pattern}, attrs...), " ")
+			lines = append(lines, line)
+			changed = append(changed, line)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	return changed, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// runGit runs git with args in dir, or the current directory when dir is
+// empty, and returns its trimmed output.
+func runGit(dir string, args ...string) (string, error) {
+	sub := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+
+	var stderr bytes.Buffer
+	git := osexec.Command("git", args...)
+	git.Stderr = &stderr
+	out, err := git.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", sub, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", sub, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitShow returns the contents of the file at path as committed at the
+// git revision rev of the repository containing path.
+func gitShow(rev, path string) ([]byte, error) {
+	var stderr bytes.Buffer
+	git := osexec.Command("git", "-C", filepath.Dir(path), "show", rev+":./"+filepath.Base(path))
+	git.Stderr = &stderr
+	out, err := git.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git show %s: %s", rev, msg)
+		}
+		return nil, fmt.Errorf("git show %s: %w", rev, err)
+	}
+
+	return out, nil
+}
+
+func init() {
+	gitInstallCmd.Flags().StringSlice("pattern", []string{defaultGitPattern}, "Patterns of the vault files in .gitattributes")
+	gitInstallCmd.Flags().Bool("global", false, "Declare the merge driver in the global git config")
+
+	gitCmd.AddCommand(gitInstallCmd)
+	gitCmd.AddCommand(gitTextconvCmd)
+	rootCmd.AddCommand(gitCmd)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+func TestWriteTextconv(t *testing.T) {
+	data := []byte(`{
+		"_xsops": {"secret": "c2VjcmV0LWtleS1mb3ItdGVzdHM="},
+		"zeta": {"secret": "z", "enabled": false, "created_at": "2026-01-02T03:04:05+02:00", "updated_at": "0001-01-01T00:00:00Z"},
+		"app": {
+			"db": {"secret": "db", "enabled": true, "tags": {"team": "x", "env": "prod"},
+				"expires_at": "2026-12-31T23:00:00-05:00", "created_at": "2026-01-01T00:00:00Z",
+				"updated_at": "2026-06-01T12:00:00+02:00", "version": 4}
+		}
+	}`)
+
+	s, err := vault.Decode("xsops.secrets.json", data, vault.WithBackend(&vault.PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var got bytes.Buffer
+	writeTextconv(&got, s)
+
+	want := "app/db\n" +
+		"  secret: " + s.Fingerprint("db") + "\n" +
+		"  enabled: true\n" +
+		"  expires_at: 2027-01-01T04:00:00Z\n" +
+		"  tags: env=prod,team=x\n" +
+		"  version: 4\n" +
+		"  updated_at: 2026-06-01T10:00:00Z\n" +
+		"zeta\n" +
+		"  secret: " + s.Fingerprint("z") + "\n" +
+		"  enabled: false\n" +
+		"  expires_at: -\n" +
+		"  tags: -\n" +
+		"  version: 1\n" +
+		"  updated_at: 2026-01-02T01:04:05Z\n"
+
+	if got.String() != want {
+		t.Errorf("writeTextconv() =\n%s\nwant\n%s", got.String(), want)
+	}
+
+	if s.Fingerprint("db") == "" || bytes.Contains(got.Bytes(), []byte(`"db"`)) {
+		t.Error("writeTextconv() should show a fingerprint, not the secret")
+	}
+
+	// The output is the same on every run.
+	for i := 0; i < 5; i++ {
+		var again bytes.Buffer
+		writeTextconv(&again, s)
+		if again.String() != want {
+			t.Fatalf("writeTextconv() is not stable:\n%s", again.String())
+		}
+	}
+
+	time.Local = time.FixedZone("test", 9*60*60)
+	t.Cleanup(func() { time.Local = time.UTC })
+	var local bytes.Buffer
+	writeTextconv(&local, s)
+	if local.String() != want {
+		t.Errorf("writeTextconv() depends on the local time zone:\n%s", local.String())
+	}
+}
+
+func TestWriteTextconvWithoutKey(t *testing.T) {
+	var got bytes.Buffer
+	writeTextconv(&got, &vault.Snapshot{Records: map[string]*vault.SecretRecord{
+		"a": {Secret: "x", Enabled: true},
+	}})
+
+	if want := "a\n  secret: -\n"; !bytes.HasPrefix(got.Bytes(), []byte(want)) {
+		t.Errorf("writeTextconv() = %q, want it to start with %q", got.String(), want)
+	}
+}
+
+func TestAddGitAttributes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitattributes")
+	initial := "*.txt text\n*.secrets.json merge=xsops -diff\n"
+	if err := os.WriteFile(path, []byte(initial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := addGitAttributes(path, []string{"*.secrets.json", "vault.json"}, "merge=xsops", "diff=xsops")
+	if err != nil {
+		t.Fatalf("addGitAttributes() error = %v", err)
+	}
+
+	want := []string{"*.secrets.json merge=xsops -diff diff=xsops", "vault.json merge=xsops diff=xsops"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("addGitAttributes() = %q, want %q", changed, want)
+	}
+
+	content := "*.txt text\n*.secrets.json merge=xsops -diff diff=xsops\nvault.json merge=xsops diff=xsops\n"
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf(".gitattributes = %q, want %q", got, content)
+	}
+
+	// Running it again changes nothing.
+	changed, err = addGitAttributes(path, []string{"*.secrets.json", "vault.json"}, "merge=xsops", "diff=xsops")
+	if err != nil || len(changed) != 0 {
+		t.Errorf("addGitAttributes() again = %q, %v, want no changes", changed, err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf(".gitattributes after a second run = %q, want %q", got, content)
+	}
+
+	missing := filepath.Join(t.TempDir(), ".gitattributes")
+	if _, err := addGitAttributes(missing, []string{"*.secrets.json"}, "merge=xsops"); err != nil {
+		t.Fatalf("addGitAttributes() of a new file error = %v", err)
+	}
+	if got, _ := os.ReadFile(missing); string(got) != "*.secrets.json merge=xsops\n" {
+		t.Errorf("new .gitattributes = %q", got)
+	}
+}
+
+func TestTextconvSopsVault(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY", identity.String())
+
+	dir := t.TempDir()
+	conf := "creation_rules:\n  - encrypted_regex: '^(secret)$'\n    age: " + identity.Recipient().String() + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ".sops.yaml"), []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "xsops.secrets.json")
+	v, err := vault.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	snapshot := func(secret string) (*vault.Snapshot, []byte) {
+		t.Helper()
+		if _, err := v.Set("app/token", secret); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := vault.Decode(path, data)
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		return s, data
+	}
+
+	before, data := snapshot("hunter2")
+	if !before.CanFingerprint() {
+		t.Fatal("the sops vault has no fingerprint key")
+	}
+	if bytes.Contains(data, []byte("hunter2")) {
+		t.Fatal("the secret is stored in plaintext")
+	}
+
+	var out bytes.Buffer
+	writeTextconv(&out, before)
+	if !bytes.Contains(out.Bytes(), []byte("  secret: "+before.Fingerprint("hunter2")+"\n")) {
+		t.Errorf("writeTextconv() = %q, want the fingerprint of the secret", out.String())
+	}
+	if bytes.Contains(out.Bytes(), []byte("hunter2")) {
+		t.Errorf("writeTextconv() shows the secret: %q", out.String())
+	}
+
+	after, _ := snapshot("hunter3")
+	if after.Fingerprint("hunter2") != before.Fingerprint("hunter2") {
+		t.Error("the fingerprint key changed when the vault was written")
+	}
+
+	got := diffSecrets(before.Records, after.Records, before.Fingerprint)
+	want := []diffOutput{{
+		Key:      "app/token",
+		Status:   "changed",
+		Changes:  []string{"secret"},
+		FromHash: before.Fingerprint("hunter2"),
+		ToHash:   before.Fingerprint("hunter3"),
+	}}
+	if !reflect.DeepEqual(got, want) || want[0].FromHash == want[0].ToHash {
+		t.Errorf("diffSecrets() = %+v, want %+v", got, want)
+	}
+}
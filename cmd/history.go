@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history KEY",
+	Short: "List the versions of a secret",
+	Long: `List the versions of a secret, newest first, with when, by whom and
+how each was set. The secrets are only included with --show-secrets.
+
+Every change to a secret keeps the replaced value in the history of its
+record, up to the number of versions set by the history key of config.toml.
+Use "xsops get KEY --version N" to read a version and
+"xsops rollback KEY --version N" to restore it.`,
+	Example: `xsops history db-password
+xsops history db-password -o json --show-secrets`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		record, err := v.Get(key)
+		if err != nil {
+			errorf("Error getting secret: %v", err)
+			os.Exit(1)
+		}
+
+		showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+		results := []historyOutput{}
+		for i, version := range record.Versions() {
+			out := historyOutput{
+				Version:   version.Version,
+				Current:   i == 0,
+				CreatedAt: version.CreatedAt,
+				ChangedBy: version.ChangedBy,
+				Change:    version.Change,
+			}
+
+			if !version.UpdatedAt.IsZero() {
+				out.UpdatedAt = &version.UpdatedAt
+			}
+
+			if showSecrets {
+				out.Secret = &version.Secret
+			}
+			results = append(results, out)
+		}
+
+		writeTable := func(w *tabwriter.Writer) {
+			header := "VERSION\tCREATED\tREPLACED\tCHANGED BY\tCHANGE"
+			if showSecrets {
+				header += "\tSECRET"
+			}
+			fmt.Fprintln(w, header)
+
+			for _, r := range results {
+				version := fmt.Sprint(r.Version)
+				if r.Current {
+					version += " (current)"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s", version, formatTime(&r.CreatedAt), formatTime(r.UpdatedAt), orDash(r.ChangedBy), orDash(r.Change))
+				if showSecrets {
+					fmt.Fprintf(w, "\t%s", *r.Secret)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+
+		printOutput(cmd, results, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			writeTable(tw)
+			tw.Flush()
+		}, writeTable)
+		os.Exit(0)
+	},
+}
+
+// historyOutput is a version of a secret as emitted by history.
+type historyOutput struct {
+	Version   int        `json:"version" yaml:"version"`
+	Current   bool       `json:"current" yaml:"current"`
+	Secret    *string    `json:"secret,omitempty" yaml:"secret,omitempty"`
+	CreatedAt time.Time  `json:"created_at" yaml:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	ChangedBy string     `json:"changed_by,omitempty" yaml:"changed_by,omitempty"`
+	Change    string     `json:"change,omitempty" yaml:"change,omitempty"`
+}
+
+// orDash returns s, or "-" for empty table cells.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func init() {
+	historyCmd.Flags().Bool("show-secrets", false, "Include the secret of every version")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hyprxlabs/xsops/internal/dotenv"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [FILE]",
+	Short: "Import secrets from a dotenv, json or yaml file",
+	Long: `Import secrets from a dotenv, json or yaml file into the vault.
+
+Every value is stored as a new enabled secret and the vault is encrypted
+once for the whole import. Use "-" as the file to read from stdin.
+
+The format is detected from the file extension (.json, .yaml, .yml and
+anything else is read as dotenv) unless --format is set. Dotenv files may
+use "export" prefixes, comments and single or double quoted values that span
+multiple lines. Json and yaml files must contain a flat object of scalar
+values.
+
+By default the import fails if a key already exists in the vault. Use
+--overwrite to replace the existing values or --skip-existing to keep them.
+Use --dry-run to preview the changes without writing the vault. A dry run
+exits with the same status as the import would, so it fails when a key
+already exists.`,
+	Example: `xsops import .env
+xsops -v default import secrets.json --skip-existing
+cat .env | xsops import - --dry-run`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+		skipExisting, _ := cmd.Flags().GetBool("skip-existing")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		if overwrite && skipExisting {
+			errorf("--overwrite and --skip-existing cannot be used together.")
+			os.Exit(1)
+		}
+
+		source := args[0]
+		var data []byte
+		var err error
+		if source == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(source)
+		}
+		if err != nil {
+			errorf("Error reading %s: %v", source, err)
+			os.Exit(1)
+		}
+
+		if format == "" {
+			switch strings.ToLower(filepath.Ext(source)) {
+			case ".json":
+				format = "json"
+			case ".yaml", ".yml":
+				format = "yaml"
+			default:
+				format = "dotenv"
+			}
+		}
+
+		entries, err := parseImport(format, data)
+		if err != nil {
+			errorf("Error parsing %s: %v", source, err)
+			os.Exit(1)
+		}
+
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		plan := []importAction{}
+		err = v.Update(func(records map[string]*vault.SecretRecord) error {
+			now := time.Now().UTC()
+			plan = planImport(records, entries, overwrite, skipExisting)
+			for _, action := range plan {
+				if action.Conflict {
+					continue
+				}
+
+				switch action.Op {
+				case "add":
+					records[action.Key] = &vault.SecretRecord{
+						Secret:    action.Value,
+						CreatedAt: now,
+						Enabled:   true,
+					}
+				case "overwrite":
+					records[action.Key].Secret = action.Value
+					records[action.Key].UpdatedAt = now
+				}
+			}
+
+			for _, action := range plan {
+				if action.Conflict {
+					return fmt.Errorf("key '%s' already exists, use --overwrite or --skip-existing", action.Key)
+				}
+			}
+
+			if dryRun {
+				return errDryRun
+			}
+
+			return nil
+		}, vault.WithChange("import"))
+
+		result := importOutput{DryRun: dryRun, Actions: plan}
+		printOutput(cmd, result, func(w io.Writer) {
+			for _, action := range plan {
+				switch {
+				case action.Conflict:
+					color.New(color.FgRed).Fprintf(w, "! %s (already exists)\n", action.Key)
+				case action.Op == "add":
+					color.New(color.FgGreen).Fprintf(w, "+ %s\n", action.Key)
+				case action.Op == "overwrite":
+					color.New(color.FgYellow).Fprintf(w, "~ %s (overwrite)\n", action.Key)
+				case action.Op == "skip":
+					fmt.Fprintf(w, "= %s (skipped, already exists)\n", action.Key)
+				}
+			}
+		}, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "KEY\tACTION\tCONFLICT")
+			for _, action := range plan {
+				fmt.Fprintf(w, "%s\t%s\t%t\n", action.Key, action.Op, action.Conflict)
+			}
+		})
+
+		if dryRun && errors.Is(err, errDryRun) {
+			os.Exit(0)
+		}
+
+		if err != nil {
+			errorf("Error importing secrets: %v", err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	},
+}
+
+var errDryRun = errors.New("dry run")
+
+type importAction struct {
+	Key      string `json:"key" yaml:"key"`
+	Value    string `json:"-" yaml:"-"`
+	Op       string `json:"action" yaml:"action"`
+	Conflict bool   `json:"conflict" yaml:"conflict"`
+}
+
+type importOutput struct {
+	DryRun  bool           `json:"dry_run" yaml:"dry_run"`
+	Actions []importAction `json:"actions" yaml:"actions"`
+}
+
+// planImport decides what happens to each entry given the existing
+// records and the conflict mode. Later entries with the same key win.
+func planImport(records map[string]*vault.SecretRecord, entries []dotenv.Entry, overwrite, skipExisting bool) []importAction {
+	index := map[string]int{}
+	plan := []importAction{}
+	for _, e := range entries {
+		action := importAction{Key: e.Key, Value: e.Value, Op: "add"}
+		if _, exists := records[e.Key]; exists {
+			switch {
+			case overwrite:
+				action.Op = "overwrite"
+			case skipExisting:
+				action.Op = "skip"
+			default:
+				action.Op = "overwrite"
+				action.Conflict = true
+			}
+		}
+
+		if i, ok := index[e.Key]; ok {
+			plan[i] = action
+			continue
+		}
+
+		index[e.Key] = len(plan)
+		plan = append(plan, action)
+	}
+
+	return plan
+}
+
+func parseImport(format string, data []byte) ([]dotenv.Entry, error) {
+	switch strings.ToLower(format) {
+	case "dotenv", "env":
+		return dotenv.Parse(data)
+	case "json":
+		values := map[string]any{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&values); err != nil {
+			return nil, err
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		entries := make([]dotenv.Entry, 0, len(keys))
+		for _, key := range keys {
+			switch value := values[key].(type) {
+			case string:
+				entries = append(entries, dotenv.Entry{Key: key, Value: value})
+			case json.Number:
+				entries = append(entries, dotenv.Entry{Key: key, Value: value.String()})
+			case bool:
+				entries = append(entries, dotenv.Entry{Key: key, Value: fmt.Sprint(value)})
+			default:
+				return nil, fmt.Errorf("the value of '%s' is not a string, number or boolean", key)
+			}
+		}
+
+		return entries, nil
+	case "yaml", "yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+
+		if len(doc.Content) == 0 {
+			return []dotenv.Entry{}, nil
+		}
+
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("expected a mapping at the top level")
+		}
+
+		entries := make([]dotenv.Entry, 0, len(root.Content)/2)
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			if value.Kind != yaml.ScalarNode || value.Tag == "!!null" {
+				return nil, fmt.Errorf("line %d: the value of '%s' is not a scalar", value.Line, key.Value)
+			}
+			entries = append(entries, dotenv.Entry{Key: key.Value, Value: value.Value, Line: key.Line})
+		}
+
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("unknown import format '%s'", format)
+	}
+}
+
+func init() {
+	importCmd.Flags().StringP("format", "F", "", "Input format: dotenv, json or yaml (detected from the extension by default)")
+	importCmd.Flags().Bool("overwrite", false, "Overwrite secrets that already exist")
+	importCmd.Flags().Bool("skip-existing", false, "Keep secrets that already exist")
+	importCmd.Flags().Bool("dry-run", false, "Show what would be imported without writing the vault")
+	rootCmd.AddCommand(importCmd)
+}
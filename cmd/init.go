@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/fatih/color"
-	"github.com/hyprxlabs/go/exec"
+	"filippo.io/age"
+	"github.com/hyprxlabs/xsops/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -21,13 +24,16 @@ var initCmd = &cobra.Command{
 	home config directory.
 
 	It creates a default sops configuration file and an empty secrets file 
-	the directory.  The default name for a secrets file is xsops.secrets.json.`,
+	the directory.  The default name for a secrets file is xsops.secrets.json.
+
+	Vaults registered with "xsops vault add --recipient" are encrypted for
+	those age recipients instead of the keys of the .sops.yaml file.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		os.Setenv("XSOPS_CONFIG_HOME", "")
 		homeConfig, err := os.UserConfigDir()
 
 		if err != nil {
-			color.Red("[ERROR]: Error getting home config: %v", err)
+			errorf("Error getting home config: %v", err)
 			os.Exit(1)
 		}
 
@@ -36,18 +42,20 @@ var initCmd = &cobra.Command{
 		if _, err := os.Stat(sopsAgeKey); os.IsNotExist(err) {
 			dir := filepath.Dir(sopsAgeKey)
 			if err := os.MkdirAll(dir, 0700); err != nil {
-				color.Red("[ERROR]: Error creating directory: %v", err)
+				errorf("Error creating directory: %v", err)
 				os.Exit(1)
 			}
 
-			exec.New("age-keygen", "-o", sopsAgeKey).Run()
-
+			if err := writeAgeKey(sopsAgeKey); err != nil {
+				errorf("Error generating age key: %v", err)
+				os.Exit(1)
+			}
 		}
 
 		if _, err := os.Stat(xsopsDefaultSopsConfig); os.IsNotExist(err) {
 			keyContent, err := os.ReadFile(sopsAgeKey)
 			if err != nil {
-				color.Red("[ERROR]: Error reading age key file: %v", err)
+				errorf("Error reading age key file: %v", err)
 				os.Exit(1)
 			}
 			publicKey := ""
@@ -71,21 +79,24 @@ creation_rules:
       ` + publicKey + `
 `
 
+			if err := os.MkdirAll(filepath.Dir(xsopsDefaultSopsConfig), 0755); err != nil {
+				errorf("Error creating directory: %v", err)
+				os.Exit(1)
+			}
+
 			if err := os.WriteFile(xsopsDefaultSopsConfig, []byte(sopsConfig), 0644); err != nil {
-				color.Red("[ERROR]: Error writing sops config file: %v", err)
+				errorf("Error writing sops config file: %v", err)
 				os.Exit(1)
 			}
 		}
 
-		vault, _ := cmd.Flags().GetString("vault")
+		name := getVaultName(cmd)
 		debug, _ := cmd.Flags().GetBool("debug")
 
-		uriString := vault
-
-		fileName, err := getFilePath(uriString)
+		fileName, entry, err := resolveVault(name)
 		if err != nil {
 			if debug {
-				color.Red("[ERROR]: Error getting file path: %v", err)
+				errorf("Error getting file path: %v", err)
 			}
 			os.Exit(1)
 		}
@@ -96,45 +107,73 @@ creation_rules:
 		if _, err := os.Stat(sopsFile); os.IsNotExist(err) {
 			configBytes, err := os.ReadFile(xsopsDefaultSopsConfig)
 			if err != nil {
-				color.Red("[ERROR]: Error reading default sops config: %v", err)
+				errorf("Error reading default sops config: %v", err)
 				os.Exit(1)
 			}
 
 			if _, err := os.Stat(dir); os.IsNotExist(err) {
 				if err := os.MkdirAll(dir, 0755); err != nil {
-					color.Red("[ERROR]: Error creating directory: %v", err)
+					errorf("Error creating directory: %v", err)
 					os.Exit(1)
 				}
 			}
 
 			if err := os.WriteFile(sopsFile, configBytes, 0644); err != nil {
-				color.Red("[ERROR]: Error writing sops config file: %v", err)
+				errorf("Error writing sops config file: %v", err)
 				os.Exit(1)
 			}
 		}
 
+		result := initOutput{Vault: fileName, SopsConfig: sopsFile, AgeKey: sopsAgeKey}
 		if _, err := os.Stat(fileName); os.IsNotExist(err) {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				color.Red("[ERROR]: Error creating directory: %v", err)
-				os.Exit(1)
+			// New vaults are encrypted locally so that the recipients of a
+			// registered vault are used even when an agent is running.
+			var recipients []string
+			if entry != nil {
+				recipients = entry.Recipients
 			}
 
-			if err := os.WriteFile(fileName, []byte("{}"), 0644); err != nil {
-				color.Red("[ERROR]: Error writing secrets file: %v", err)
+			backend, err := getLocalBackend(name, recipients)
+			if err != nil {
+				errorf("Error selecting backend: %v", err)
 				os.Exit(1)
 			}
 
-			o, err := exec.New("sops", "encrypt", "-i", fileName).WithCwd(dir).Run()
-			if err != nil {
-				color.Red("[ERROR]: Error encrypting secrets file: %v", err)
+			if _, err := vault.Create(fileName, vault.WithBackend(backend)); err != nil {
+				errorf("Error creating secrets file: %v", err)
 				os.Exit(1)
 			}
-
-			os.Exit(o.Code)
+			result.Created = true
 		}
+
+		printOutput(cmd, result, nil, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "VAULT\tCREATED\tSOPS CONFIG\tAGE KEY")
+			fmt.Fprintf(w, "%s\t%t\t%s\t%s\n", result.Vault, result.Created, result.SopsConfig, result.AgeKey)
+		})
 	},
 }
 
+type initOutput struct {
+	Vault      string `json:"vault" yaml:"vault"`
+	Created    bool   `json:"created" yaml:"created"`
+	SopsConfig string `json:"sops_config" yaml:"sops_config"`
+	AgeKey     string `json:"age_key" yaml:"age_key"`
+}
+
+// writeAgeKey generates a new age identity and writes it to path in the
+// same format as age-keygen.
+func writeAgeKey(path string) error {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), identity.Recipient(), identity)
+
+	return os.WriteFile(path, []byte(content), 0600)
+}
+
 func init() {
 	initCmd.Flags().BoolP("debug", "D", false, "Enable debug mode")
 	rootCmd.AddCommand(initCmd)
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of get, run and export when a secret they would read is
+// disabled or expired.
+const (
+	exitDisabled = 3
+	exitExpired  = 4
+)
+
+var enableCmd = &cobra.Command{
+	Use:   "enable KEY...",
+	Short: "Enable secrets so they can be read again",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setEnabled(cmd, args, true)
+	},
+}
+
+var disableCmd = &cobra.Command{
+	Use:   "disable KEY...",
+	Short: "Disable secrets so they are no longer read",
+	Long: `Disable secrets without removing them. get, run and export refuse
+disabled secrets with exit code 3 unless --allow-disabled is used, and
+verify reports them as failed. Use "xsops enable KEY" to enable them again.`,
+	Example: `xsops disable old-api-token
+xsops enable old-api-token`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setEnabled(cmd, args, false)
+	},
+}
+
+// setEnabled enables or disables the secrets stored under keys in a
+// single write and exits.
+func setEnabled(cmd *cobra.Command, keys []string, enabled bool) {
+	v, err := openVault(cmd)
+	if err != nil {
+		errorf("Error opening vault: %v", err)
+		os.Exit(1)
+	}
+
+	missing := ""
+	results := []recordOutput{}
+	err = v.Update(func(records map[string]*vault.SecretRecord) error {
+		now := time.Now().UTC()
+		for _, key := range keys {
+			record, ok := records[key]
+			if !ok {
+				missing = key
+				return vault.ErrKeyNotFound
+			}
+
+			if record.Enabled != enabled {
+				record.Enabled = enabled
+				record.UpdatedAt = now
+			}
+			results = append(results, newRecordOutput(key, record, false))
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, vault.ErrKeyNotFound) {
+			errorf("Secret '%s' does not exist in %s.", missing, v.Path())
+			os.Exit(1)
+		}
+		errorf("Error updating secrets: %v", err)
+		os.Exit(1)
+	}
+
+	printOutput(cmd, results, func(w io.Writer) {
+		for _, r := range results {
+			if enabled {
+				infof("Enabled %s.", r.Key)
+			} else {
+				infof("Disabled %s.", r.Key)
+			}
+		}
+	}, func(w *tabwriter.Writer) {
+		writeRecordTable(w, results)
+	})
+	os.Exit(0)
+}
+
+// addLifecycleFlags adds the flags that allow reading disabled and
+// expired secrets to cmd.
+func addLifecycleFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("allow-disabled", false, "Read disabled secrets instead of failing")
+	cmd.Flags().Bool("allow-expired", false, "Read expired secrets instead of failing")
+}
+
+// checkLifecycle returns the records that can be read at now according to
+// the flags of cmd. Disabled and expired records selected in bulk, by a
+// glob, a tag or the whole vault, are skipped with a warning. When one of
+// the keys for which named returns true, the keys the user asked for by
+// name, is disabled or expired checkLifecycle exits with exitDisabled or
+// exitExpired instead.
+func checkLifecycle(cmd *cobra.Command, records map[string]*vault.SecretRecord, named func(key string) bool, now time.Time) map[string]*vault.SecretRecord {
+	allowDisabled, _ := cmd.Flags().GetBool("allow-disabled")
+	allowExpired, _ := cmd.Flags().GetBool("allow-expired")
+	includeDisabled, _ := cmd.Flags().GetBool("include-disabled")
+	includeExpired, _ := cmd.Flags().GetBool("include-expired")
+
+	readable, skipped, disabled, expired := filterLifecycle(records, named,
+		allowDisabled || includeDisabled, allowExpired || includeExpired, now)
+
+	if len(disabled) > 0 {
+		errorf("Disabled secrets cannot be read without --allow-disabled: %s", joinSorted(disabled))
+		os.Exit(exitDisabled)
+	}
+
+	if len(expired) > 0 {
+		errorf("Expired secrets cannot be read without --allow-expired: %s", joinSorted(expired))
+		os.Exit(exitExpired)
+	}
+
+	if len(skipped) > 0 {
+		warnf("Skipping disabled or expired secrets, use --allow-disabled or --allow-expired to include them: %s", joinSorted(skipped))
+	}
+
+	return readable
+}
+
+// filterLifecycle splits records into the ones that can be read at now
+// and the keys of the disabled or expired ones that are not allowed. Those
+// are returned in disabled or expired when named reports the key was
+// asked for by name, and in skipped otherwise.
+func filterLifecycle(records map[string]*vault.SecretRecord, named func(key string) bool, allowDisabled, allowExpired bool, now time.Time) (readable map[string]*vault.SecretRecord, skipped, disabled, expired []string) {
+	readable = map[string]*vault.SecretRecord{}
+	for key, record := range records {
+		isDisabled := !record.Enabled && !allowDisabled
+		isExpired := record.IsExpired(now) && !allowExpired
+		switch {
+		case !isDisabled && !isExpired:
+			readable[key] = record
+		case !named(key):
+			skipped = append(skipped, key)
+		case isDisabled:
+			disabled = append(disabled, key)
+		default:
+			expired = append(expired, key)
+		}
+	}
+
+	slices.Sort(skipped)
+	slices.Sort(disabled)
+	slices.Sort(expired)
+	return readable, skipped, disabled, expired
+}
+
+// namedKeys returns a function that reports whether a key is named
+// exactly, without wildcards, by one of patterns.
+func namedKeys(patterns ...string) func(key string) bool {
+	return func(key string) bool {
+		for _, p := range patterns {
+			if p == key && !strings.ContainsAny(p, `*?[{\`) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// joinSorted sorts keys and joins them with commas.
+func joinSorted(keys []string) string {
+	slices.Sort(keys)
+	return strings.Join(keys, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(enableCmd)
+	rootCmd.AddCommand(disableCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+func TestFilterLifecycle(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	records := map[string]*vault.SecretRecord{
+		"ok":       {Enabled: true},
+		"disabled": {Enabled: false},
+		"expired":  {Enabled: true, ExpiresAt: &past},
+	}
+
+	tests := []struct {
+		name                        string
+		named                       func(key string) bool
+		allowDisabled, allowExpired bool
+		readable                    []string
+		skipped, disabled, expired  []string
+	}{
+		{
+			name:     "bulk selection skips",
+			named:    namedKeys(),
+			readable: []string{"ok"},
+			skipped:  []string{"disabled", "expired"},
+		},
+		{
+			name:     "glob is not a name",
+			named:    namedKeys("*"),
+			readable: []string{"ok"},
+			skipped:  []string{"disabled", "expired"},
+		},
+		{
+			name:     "named keys are refused",
+			named:    namedKeys("disabled", "expired"),
+			readable: []string{"ok"},
+			disabled: []string{"disabled"},
+			expired:  []string{"expired"},
+		},
+		{
+			name:          "allowed",
+			named:         namedKeys("disabled"),
+			allowDisabled: true,
+			allowExpired:  true,
+			readable:      []string{"disabled", "expired", "ok"},
+		},
+		{
+			name:          "allow disabled only",
+			named:         namedKeys("expired"),
+			allowDisabled: true,
+			readable:      []string{"disabled", "ok"},
+			expired:       []string{"expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readable, skipped, disabled, expired := filterLifecycle(records, tt.named, tt.allowDisabled, tt.allowExpired, now)
+
+			keys := []string{}
+			for key := range readable {
+				keys = append(keys, key)
+			}
+			if got := joinSorted(keys); got != joinSorted(tt.readable) {
+				t.Errorf("readable = %s, want %s", got, joinSorted(tt.readable))
+			}
+
+			for _, c := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"skipped", skipped, tt.skipped},
+				{"disabled", disabled, tt.disabled},
+				{"expired", expired, tt.expired},
+			} {
+				if len(c.got) != 0 || len(c.want) != 0 {
+					if !reflect.DeepEqual(c.got, c.want) {
+						t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+					}
+				}
+			}
+		})
+	}
+}
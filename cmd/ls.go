@@ -1,13 +1,18 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/gobwas/glob"
-	"github.com/hyprxlabs/go/exec"
+	"github.com/hyprxlabs/xsops/internal/query"
+	"github.com/hyprxlabs/xsops/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -16,67 +21,176 @@ var lsCmd = &cobra.Command{
 	Short: "List all secrets in the secrets database",
 	Long: `List all secrets in the secrets database using its URI.
 	
-Use the --match flag to filter secrets by glob pattern.`,
+Keys can be grouped in namespaces separated by slashes, such as
+app/db/password, which are stored as nested objects. Use --tree to list the
+keys as a tree of their namespaces.
+
+Use the --match flag to filter secrets by glob pattern and --where to filter
+them by tags, state and dates. In patterns * and ? match within a namespace
+and ** matches across namespaces, so "app/*" matches app/token but not
+app/db/password:
+
+    xsops ls --where "env=prod and expires_at < 30d"
+    xsops ls --where "not enabled or updated_at < 90d ago"
+
+--where compares the fields key, enabled, disabled, expired, version,
+created_at, updated_at and expires_at, or any tag, with =, !=, <, <=, >, >=
+and ~ (glob match), combined with and, or, not and parentheses. A field on
+its own tests that a tag exists or a date is set. Dates are written as
+2026-01-31, RFC 3339 times, now or durations from now such as 30d, 2w or
+90d ago.
+
+Keys are listed in sorted order. Use --output table, json or yaml to include
+the metadata of each secret, such as its tags and expiration time. The
+secrets themselves are never listed.`,
 	Example: `xsops -v default ls
 	xsops default ls --match "*prod*"
+	xsops ls --where "env=prod and expires_at < 30d" -o table
 	xsops -v ./xsops.secrets.json ls 
 	xsops -v sops:///path/to/secrets.json ls `,
 	Run: func(cmd *cobra.Command, args []string) {
 		debug, _ := cmd.Flags().GetBool("debug")
-		vault, _ := cmd.Flags().GetString("vault")
-		uriString := vault
+		match, _ := cmd.Flags().GetString("match")
 
-		filePath, err := getFilePath(uriString)
+		var g glob.Glob
+		if match != "" {
+			var err error
+			if g, err = vault.CompileGlob(match); err != nil {
+				errorf("Invalid --match pattern '%s': %v", match, err)
+				os.Exit(1)
+			}
+		}
+		where := compileWhere(cmd)
+
+		v, err := openVault(cmd)
 		if err != nil {
 			if debug {
-				color.Red("[ERROR]: Error getting file path: %v", err)
+				errorf("Error opening vault: %v", err)
 			}
 			os.Exit(1)
 		}
 
-		dir := filepath.Dir(filePath)
-
-		cmd0 := exec.New("sops", "decrypt", filePath)
-		cmd0.Dir = dir
-
-		res, err := cmd0.Output()
+		records, err := v.All()
 		if err != nil {
 			if debug {
-				color.Red("[ERROR]: Error executing sops command: %v", err)
+				errorf("Error listing secrets: %v", err)
 			}
 			os.Exit(1)
 		}
 
-		var secrets map[string]interface{}
-		if err := json.Unmarshal(res.Stdout, &secrets); err != nil {
-			if debug {
-				color.Red("[ERROR]: Error unmarshalling JSON: %v", err)
+		keys := make([]string, 0, len(records))
+		for key := range records {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		now := time.Now()
+		results := []recordOutput{}
+		for _, key := range keys {
+			if g != nil && !g.Match(key) {
+				continue
 			}
-			os.Exit(1)
+
+			if where != nil && !where.Match(key, records[key], now) {
+				continue
+			}
+			results = append(results, newRecordOutput(key, records[key], false))
 		}
 
-		filter, _ := cmd.Flags().GetString("filter")
-		if filter == "" {
-			for key := range secrets {
-				if key != "sops" {
-					color.Blue("%s", key)
+		tree, _ := cmd.Flags().GetBool("tree")
+		printOutput(cmd, results, func(w io.Writer) {
+			blue := color.New(color.FgBlue)
+			if tree {
+				keys := make([]string, 0, len(results))
+				for _, r := range results {
+					keys = append(keys, r.Key)
 				}
+				writeKeyTree(w, keys, blue)
+				return
 			}
-		} else {
-			g := glob.MustCompile(filter)
-			for key := range secrets {
-				if key != "sops" && g.Match(key) {
-					color.Blue("%s", key)
-				}
+
+			for _, r := range results {
+				blue.Fprintln(w, r.Key)
 			}
-		}
+		}, func(w *tabwriter.Writer) {
+			writeRecordTable(w, results)
+		})
 
 		os.Exit(0)
 	},
 }
 
+// keyNode is a namespace or secret of the tree printed by ls --tree.
+type keyNode struct {
+	name     string
+	secret   bool
+	children []*keyNode
+}
+
+// writeKeyTree writes the sorted keys as a tree of their namespaces,
+// coloring the secrets with c.
+func writeKeyTree(w io.Writer, keys []string, c *color.Color) {
+	root := &keyNode{}
+	for _, key := range keys {
+		node := root
+		for _, segment := range strings.Split(key, vault.Separator) {
+			var child *keyNode
+			if n := len(node.children); n > 0 && node.children[n-1].name == segment && !node.children[n-1].secret {
+				child = node.children[n-1]
+			} else {
+				child = &keyNode{name: segment}
+				node.children = append(node.children, child)
+			}
+			node = child
+		}
+		node.secret = true
+	}
+
+	var walk func(nodes []*keyNode, indent string, top bool)
+	walk = func(nodes []*keyNode, indent string, top bool) {
+		for i, node := range nodes {
+			branch, next := "├── ", "│   "
+			if i == len(nodes)-1 {
+				branch, next = "└── ", "    "
+			}
+			if top {
+				branch, next = "", ""
+			}
+
+			if node.secret {
+				fmt.Fprint(w, indent+branch)
+				c.Fprintln(w, node.name)
+				continue
+			}
+
+			fmt.Fprintln(w, indent+branch+node.name+vault.Separator)
+			walk(node.children, indent+next, false)
+		}
+	}
+	walk(root.children, "", true)
+}
+
+// compileWhere parses the --where flag of cmd, exiting when it is
+// invalid. It returns nil when the flag is not set.
+func compileWhere(cmd *cobra.Command) *query.Query {
+	where, _ := cmd.Flags().GetString("where")
+	if where == "" {
+		return nil
+	}
+
+	q, err := query.Parse(where)
+	if err != nil {
+		errorf("Invalid --where query '%s': %v", where, err)
+		os.Exit(1)
+	}
+
+	return q
+}
+
 func init() {
 	lsCmd.Flags().BoolP("debug", "d", false, "Enable debug mode")
-	lsCmd.Flags().StringP("match", "m", "", "Filter secrets by glob pattern")
+	lsCmd.Flags().StringP("match", "m", "", "Filter secrets by glob pattern, * matches within a namespace and ** across namespaces")
+	lsCmd.Flags().BoolP("tree", "T", false, "List the keys as a tree of their namespaces")
+	lsCmd.Flags().StringP("where", "w", "", "Filter secrets by tags, state and dates, such as \"env=prod and expires_at < 30d\"")
 	rootCmd.AddCommand(lsCmd)
 }
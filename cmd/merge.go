@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var mergeDriverCmd = &cobra.Command{
+	Use:   "merge-driver BASE OURS THEIRS [PATH]",
+	Short: "Merge two versions of a vault file, for use as a git merge driver",
+	Long: `Merge the vault files OURS and THEIRS, both changed from BASE, key by key
+and write the result to OURS encrypted with its data key and recipients.
+PATH is the path of the vault in the repository, used to find its
+settings and .sops.yaml.
+
+A key changed or removed on one side only takes that change, and changes
+to different fields of a key, such as its tags on one side and its
+expiration on the other, are combined. Keys with the same field changed
+differently on both sides are conflicts: they keep the version of OURS, are
+listed on stderr and the exit code is 1 so git reports the merge as
+conflicted. Resolve them with "xsops set" or "xsops rollback" and commit.
+Recipients changed in THEIRS only are a conflict too, since the result
+keeps the recipients of OURS.
+
+Use "xsops git install" to configure git to run this command as
+merge-driver %O %A %B %P for vault files.`,
+	Args: cobra.RangeArgs(3, 4),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[1]
+		if len(args) == 4 {
+			name = args[3]
+		}
+
+		path, err := filepath.Abs(name)
+		if err != nil {
+			errorf("Error resolving %s: %v", name, err)
+			os.Exit(2)
+		}
+
+		backend, err := getBackend(path)
+		if err != nil {
+			errorf("Error creating backend: %v", err)
+			os.Exit(2)
+		}
+
+		versions := make([]map[string]*vault.SecretRecord, 3)
+		recipients := make([][]string, 3)
+		var ours []byte
+		for i, file := range args[:3] {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				errorf("Error reading %s: %v", file, err)
+				os.Exit(2)
+			}
+
+			if len(data) > 0 {
+				if recipients[i], err = vault.Recipients(data); err != nil {
+					errorf("Error reading the recipients of %s: %v", file, err)
+					os.Exit(2)
+				}
+			}
+
+			// The base is empty when both sides added the vault.
+			if len(data) == 0 {
+				versions[i] = map[string]*vault.SecretRecord{}
+				continue
+			}
+
+			if i == 1 {
+				ours = data
+			}
+
+			snapshot, err := vault.Decode(path, data, vault.WithBackend(backend))
+			if err != nil {
+				errorf("Error decrypting %s: %v", name, err)
+				os.Exit(2)
+			}
+			versions[i] = snapshot.Records
+		}
+
+		merged, conflicts := vault.Merge(versions[0], versions[1], versions[2])
+		out, err := vault.Encode(path, merged, ours, vault.WithBackend(backend))
+		if err != nil {
+			errorf("Error encrypting the merged %s: %v", name, err)
+			os.Exit(2)
+		}
+
+		if err := os.WriteFile(args[1], out, 0644); err != nil {
+			errorf("Error writing %s: %v", args[1], err)
+			os.Exit(2)
+		}
+
+		// The merged vault is encrypted for the recipients of ours, so a
+		// change to the recipients made on theirs only would be lost.
+		recipientsChanged := !slices.Equal(recipients[2], recipients[0]) && !slices.Equal(recipients[2], recipients[1])
+		if recipientsChanged {
+			errorf("Conflict in %s: the recipients were changed on theirs, keeping ours. Run \"sops updatekeys\" to apply them.", name)
+		}
+
+		for _, key := range conflicts {
+			errorf("Conflict in %s: '%s' was changed on both sides, keeping ours.", name, key)
+		}
+
+		if recipientsChanged || len(conflicts) > 0 {
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeDriverCmd)
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv SRC [DST] | --match GLOB [NAMESPACE]",
+	Short: "Rename secrets or move them to another vault",
+	Long: `Rename the secret stored under SRC to DST, or move it to the vault given
+with --to. The whole record is moved with its tags, dates and history, and
+is encrypted for the recipients of the destination vault.
+
+With --match every secret matching the glob pattern is moved. The part of
+the keys before the first wildcard is replaced with NAMESPACE, so
+"--match 'old/**' new" moves old/db/password to new/db/password. Without
+NAMESPACE the keys are kept, which requires --to.
+
+Existing secrets are never overwritten unless --force is used.`,
+	Example: `xsops mv db-password app/db/password
+xsops mv api-token --to team
+xsops mv --match "old/**" new
+xsops mv --match "app/**" --to prod --force`,
+	Run: func(cmd *cobra.Command, args []string) {
+		transferSecrets(cmd, args, true)
+	},
+}
+
+var cpCmd = &cobra.Command{
+	Use:   "cp SRC [DST] | --match GLOB [NAMESPACE]",
+	Short: "Copy secrets within a vault or to another vault",
+	Long: `Copy the secret stored under SRC to DST, or to the vault given with --to.
+The whole record is copied with its tags, dates and history, and is
+encrypted for the recipients of the destination vault.
+
+With --match every secret matching the glob pattern is copied. The part of
+the keys before the first wildcard is replaced with NAMESPACE, see
+"xsops mv --help".
+
+Existing secrets are never overwritten unless --force is used.`,
+	Example: `xsops cp db-password db-password-backup
+xsops cp --match "app/**" --to staging`,
+	Run: func(cmd *cobra.Command, args []string) {
+		transferSecrets(cmd, args, false)
+	},
+}
+
+// transferOutput is a secret copied or moved by cp or mv.
+type transferOutput struct {
+	Key   string `json:"key" yaml:"key"`
+	To    string `json:"to" yaml:"to"`
+	Vault string `json:"vault" yaml:"vault"`
+}
+
+// transferSecrets copies, or moves when move is true, the secrets
+// selected by args and the --match flag of cmd and exits.
+func transferSecrets(cmd *cobra.Command, args []string, move bool) {
+	name, verb, doing := "cp", "Copied", "copying"
+	if move {
+		name, verb, doing = "mv", "Moved", "moving"
+	}
+
+	match, _ := cmd.Flags().GetString("match")
+	to, _ := cmd.Flags().GetString("to")
+	force, _ := cmd.Flags().GetBool("force")
+
+	if (match == "" && (len(args) < 1 || len(args) > 2)) || (match != "" && len(args) > 1) {
+		errorf("You must provide a key and its new key, or --match and a namespace.")
+		usagef("Usage: xsops %s SRC [DST] | --match GLOB [NAMESPACE] [--to VAULT]", name)
+		os.Exit(1)
+	}
+
+	src, err := openVault(cmd)
+	if err != nil {
+		errorf("Error opening vault: %v", err)
+		os.Exit(1)
+	}
+
+	dst := src
+	if to != "" {
+		if dst, err = openNamedVault(to); err != nil {
+			errorf("Error opening vault '%s': %v", to, err)
+			os.Exit(1)
+		}
+	}
+
+	renames := map[string]string{}
+	if match == "" {
+		renames[args[0]] = args[0]
+		if len(args) == 2 {
+			renames[args[0]] = args[1]
+		}
+	} else {
+		g, err := vault.CompileGlob(match)
+		if err != nil {
+			errorf("Invalid --match pattern '%s': %v", match, err)
+			os.Exit(1)
+		}
+
+		keys, err := src.List()
+		if err != nil {
+			errorf("Error reading secrets: %v", err)
+			os.Exit(1)
+		}
+
+		prefix := globPrefix(match)
+		for _, key := range keys {
+			if !g.Match(key) {
+				continue
+			}
+
+			renames[key] = key
+			if len(args) == 1 {
+				renames[key] = strings.TrimSuffix(args[0], vault.Separator) + vault.Separator + strings.TrimPrefix(key, prefix)
+			}
+		}
+
+		if len(renames) == 0 {
+			warnf("No secrets match '%s'.", match)
+			os.Exit(0)
+		}
+	}
+
+	results := []transferOutput{}
+	for key, newKey := range renames {
+		if key == newKey && dst.Path() == src.Path() {
+			errorf("Secret '%s' would be %s onto itself, give a new key or --to.", key, strings.ToLower(verb))
+			os.Exit(1)
+		}
+		results = append(results, transferOutput{Key: key, To: newKey, Vault: dst.Path()})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+
+	if move {
+		err = src.Move(dst, renames, force)
+	} else {
+		err = src.Copy(dst, renames, force)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, vault.ErrKeyExists):
+			errorf("%v", err)
+			usagef("Use --force to overwrite existing secrets.")
+		case errors.Is(err, vault.ErrKeyNotFound) && match == "":
+			errorf("Secret '%s' does not exist in %s.", args[0], src.Path())
+		default:
+			errorf("Error %s secrets: %v", doing, err)
+		}
+		os.Exit(1)
+	}
+
+	printOutput(cmd, results, func(w io.Writer) {
+		for _, r := range results {
+			if dst.Path() == src.Path() {
+				infof("%s %s to %s.", verb, r.Key, r.To)
+			} else {
+				infof("%s %s to %s in %s.", verb, r.Key, r.To, r.Vault)
+			}
+		}
+	}, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "KEY\tTO\tVAULT")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Key, r.To, r.Vault)
+		}
+	})
+	os.Exit(0)
+}
+
+// globPrefix returns the namespaces of pattern before its first
+// wildcard, including the trailing separator.
+func globPrefix(pattern string) string {
+	literal := pattern
+	if i := strings.IndexAny(pattern, `*?[{\`); i >= 0 {
+		literal = pattern[:i]
+	}
+
+	if i := strings.LastIndex(literal, vault.Separator); i >= 0 {
+		return literal[:i+1]
+	}
+
+	return ""
+}
+
+func init() {
+	for _, c := range []*cobra.Command{mvCmd, cpCmd} {
+		c.Flags().StringP("match", "m", "", "Select every secret whose key matches the glob pattern")
+		c.Flags().StringP("to", "t", "", "Path, URI or registered name of the destination vault")
+		c.Flags().BoolP("force", "f", false, "Overwrite secrets that already exist in the destination")
+		rootCmd.AddCommand(c)
+	}
+}
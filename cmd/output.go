@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for the --output flag. The text format is the default
+// and prints the plain results, such as only the secret for get.
+const (
+	outputText  = "text"
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// recordOutput is a secret record as emitted by --output. The secret is
+// only set for commands that return secrets such as get and ensure.
+type recordOutput struct {
+	Key       string             `json:"key" yaml:"key"`
+	Secret    *string            `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Enabled   bool               `json:"enabled" yaml:"enabled"`
+	Version   int                `json:"version" yaml:"version"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Tags      map[string]*string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	CreatedAt *time.Time         `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	UpdatedAt *time.Time         `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+}
+
+func newRecordOutput(key string, record *vault.SecretRecord, withSecret bool) recordOutput {
+	out := recordOutput{
+		Key:       key,
+		Enabled:   record.Enabled,
+		Version:   record.CurrentVersion(),
+		ExpiresAt: record.ExpiresAt,
+		Tags:      record.Tags,
+	}
+
+	if withSecret {
+		out.Secret = &record.Secret
+	}
+
+	if !record.CreatedAt.IsZero() {
+		out.CreatedAt = &record.CreatedAt
+	}
+
+	if !record.UpdatedAt.IsZero() {
+		out.UpdatedAt = &record.UpdatedAt
+	}
+
+	return out
+}
+
+// getOutput returns the output format from the --output flag, falling
+// back to XSOPS_OUTPUT and the output key of config.toml.
+func getOutput(cmd *cobra.Command) string {
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		output, _ = getSetting(getVaultName(cmd), "output")
+	}
+
+	output = strings.ToLower(output)
+	if output == "" {
+		return outputText
+	}
+
+	return output
+}
+
+// checkOutput exits when the --output flag is not a known format.
+func checkOutput(cmd *cobra.Command) {
+	switch getOutput(cmd) {
+	case outputText, outputTable, outputJSON, outputYAML:
+	default:
+		errorf("Unknown output format '%s', expected text, table, json or yaml", getOutput(cmd))
+		os.Exit(1)
+	}
+}
+
+// isStructured reports whether the --output flag asks for json or yaml.
+func isStructured(cmd *cobra.Command) bool {
+	output := getOutput(cmd)
+	return output == outputJSON || output == outputYAML
+}
+
+// printOutput writes result to stdout in the format of the --output flag.
+// json and yaml marshal result; table calls table and text calls text.
+// When table is nil text is used for both.
+func printOutput(cmd *cobra.Command, result any, text func(w io.Writer), table func(w *tabwriter.Writer)) {
+	switch getOutput(cmd) {
+	case outputJSON:
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			errorf("Error encoding output: %v", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		os.Stdout.WriteString("\n")
+	case outputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(result); err != nil {
+			errorf("Error encoding output: %v", err)
+			os.Exit(1)
+		}
+		enc.Close()
+	case outputTable:
+		if table == nil {
+			text(os.Stdout)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(w)
+		w.Flush()
+	default:
+		if text != nil {
+			text(os.Stdout)
+		}
+	}
+}
+
+// writeRecordTable writes records as a table. The secret column is only
+// shown when the records carry secrets.
+func writeRecordTable(w *tabwriter.Writer, records []recordOutput) {
+	withSecret := len(records) > 0 && records[0].Secret != nil
+	if withSecret {
+		fmt.Fprintln(w, "KEY\tSECRET\tENABLED\tEXPIRES\tTAGS\tCREATED\tUPDATED")
+	} else {
+		fmt.Fprintln(w, "KEY\tENABLED\tEXPIRES\tTAGS\tCREATED\tUPDATED")
+	}
+
+	for _, r := range records {
+		cols := []string{r.Key}
+		if withSecret {
+			cols = append(cols, *r.Secret)
+		}
+		cols = append(cols, fmt.Sprint(r.Enabled), formatTime(r.ExpiresAt), formatTags(r.Tags),
+			formatTime(r.CreatedAt), formatTime(r.UpdatedAt))
+		fmt.Fprintln(w, strings.Join(cols, "\t"))
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// formatTags formats tags as name=value pairs sorted by name.
+func formatTags(tags map[string]*string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		if tags[name] == nil {
+			pairs = append(pairs, name)
+			continue
+		}
+		pairs = append(pairs, name+"="+*tags[name])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// errorf writes an error message to stderr.
+func errorf(format string, args ...any) {
+	fmt.Fprintln(color.Error, color.RedString("[ERROR]: "+format, args...))
+}
+
+// warnf writes a warning to stderr.
+func warnf(format string, args ...any) {
+	fmt.Fprintln(color.Error, color.YellowString("[WARNING]: "+format, args...))
+}
+
+// debugf writes a debug message to stderr.
+func debugf(format string, args ...any) {
+	fmt.Fprintln(color.Error, color.CyanString("[DEBUG]: "+format, args...))
+}
+
+// infof writes an informational message to stderr so stdout only carries
+// the results of a command.
+func infof(format string, args ...any) {
+	fmt.Fprintf(color.Error, format+"\n", args...)
+}
+
+// usagef writes a usage hint to stderr.
+func usagef(format string, args ...any) {
+	fmt.Fprintln(color.Error, color.YellowString(format, args...))
+}
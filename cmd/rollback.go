@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback KEY --version N",
+	Short: "Restore a prior version of a secret",
+	Long: `Restore version N of a secret from its history, as listed by
+"xsops history KEY". The restored secret becomes a new version, so the
+secret it replaces is kept in the history and the rollback can itself be
+rolled back.`,
+	Example: `xsops history db-password
+xsops rollback db-password --version 3`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+		n, _ := cmd.Flags().GetInt("version")
+
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		record, err := v.Rollback(key, n)
+		if err != nil {
+			if errors.Is(err, vault.ErrVersionNotFound) {
+				errorf("Secret '%s' has no version %d, see \"xsops history %s\".", key, n, key)
+				os.Exit(1)
+			}
+			errorf("Error rolling back secret: %v", err)
+			os.Exit(1)
+		}
+
+		result := newRecordOutput(key, record, false)
+		printOutput(cmd, result, func(w io.Writer) {
+			if record.CurrentVersion() == n {
+				infof("Secret '%s' is already at version %d.", key, n)
+				return
+			}
+			infof("Rolled back '%s' to version %d as version %d.", key, n, record.CurrentVersion())
+		}, func(w *tabwriter.Writer) {
+			writeRecordTable(w, []recordOutput{result})
+		})
+		os.Exit(0)
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().Int("version", 0, "Version of the secret to restore")
+	rollbackCmd.MarkFlagRequired("version")
+	rootCmd.AddCommand(rollbackCmd)
+}
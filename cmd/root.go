@@ -6,7 +6,7 @@ package cmd
 import (
 	"os"
 
-	"github.com/hyprxlabs/go/env"
+	"github.com/hyprxlabs/xsops/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -19,9 +19,20 @@ var rootCmd = &cobra.Command{
 It provides a simple interface to manage secrets securely and efficiently using sops. It 
 defaults to using age for encryption.
 
-setting the environment variable XSOPS_VAULT to a file path will set the vault to that file.
-If the environment variable XSOPS_VAULT is not set, it defaults to ./xsops.secrets.json in the current directory.
-You can also use the --vault flag to specify a different vault path.
+Use the --vault flag to specify the vault. Without it the vault is read from the
+XSOPS_VAULT environment variable, the active context set with "xsops context use"
+or the vault key of config.toml, and defaults to ./xsops.secrets.json in the
+current directory.
+
+Defaults such as the backend, editor, output format and the generator settings of
+ensure are read from config.toml in the config home, see "xsops config list".
+Every key can be overridden with an XSOPS_* environment variable, for example
+XSOPS_ENSURE_SIZE for ensure.size, and for a single vault in a [vaults.NAME] table.
+
+Use --output (or XSOPS_OUTPUT) to select the output format: text (default),
+table, json or yaml. Results are written to stdout and diagnostics such as
+errors and warnings to stderr. Colors are disabled when NO_COLOR is set or
+stdout is not a terminal.
 	
 For commands that use a URI, you can use the following formats:
 - uri: sops:///path/to/secrets.json
@@ -39,6 +50,19 @@ echo "my secret" | xsops -v sops:///path/to/secrets.json set my-secret --stdin
 xsops -v ./xsops.secrets.json get my-secret
 XSOPS_VAULT=/path/to/secrets.json xsops get my-secret
 `,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		checkOutput(cmd)
+
+		name, source := selectVault(cmd)
+		if debug, _ := cmd.Flags().GetBool("debug"); debug {
+			if context, _ := config.GetContext(); context != "" {
+				debugf("Active context: %s", context)
+			}
+			debugf("Using vault '%s' from %s", name, source)
+		}
+
+		applyAgeKeyFile(name)
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
@@ -57,11 +81,7 @@ func Execute() {
 }
 
 func init() {
-	vault := env.Get("XSOPS_VAULT")
-	if vault == "" {
-		vault = "./xsops.secrets.json"
-	}
-
-	rootCmd.PersistentFlags().StringP("vault", "v", vault, "Path to the vault file")
+	rootCmd.PersistentFlags().StringP("vault", "v", "", "Path, URI or registered name of the vault (default "+defaultVault+")")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Enable debug mode")
+	rootCmd.PersistentFlags().StringP("output", "o", "", "Output format: text, table, json or yaml (default text)")
 }
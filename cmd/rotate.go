@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [KEY]",
+	Short: "Regenerate secrets while keeping the previous value",
+	Long: `Regenerate the secret stored under KEY, or every secret matching
+--match, and keep the old value as the previous secret for the --grace
+period so consumers can accept both while they are updated. Use
+"xsops get KEY --previous" to read it.
+
+New secrets are generated with the settings stored on each secret by ensure
+or the last rotation. Secrets without stored settings use the ensure.*
+defaults of config.toml. The generator flags, such as --size and --chars,
+override the stored settings and are stored for the next rotation.
+
+The rotated secrets are not printed.`,
+	Example: `xsops rotate db-password
+xsops rotate --match "prod-*" --grace 72h
+xsops rotate api-token --size 48 --no-symbols`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		match, _ := cmd.Flags().GetString("match")
+		if (len(args) == 0) == (match == "") {
+			errorf("You must provide either a KEY or --match.")
+			usagef("Usage: xsops rotate [KEY] [--match GLOB]")
+			os.Exit(1)
+		}
+
+		var g glob.Glob
+		if match != "" {
+			var err error
+			if g, err = vault.CompileGlob(match); err != nil {
+				errorf("Invalid --match pattern '%s': %v", match, err)
+				os.Exit(1)
+			}
+		}
+
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		defaults, err := configGenerator(getVaultName(cmd))
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+
+		grace, _ := cmd.Flags().GetDuration("grace")
+		now := time.Now()
+		results := []rotateOutput{}
+		err = v.Update(func(records map[string]*vault.SecretRecord) error {
+			keys := []string{}
+			if g == nil {
+				if _, ok := records[args[0]]; !ok {
+					return vault.ErrKeyNotFound
+				}
+				keys = append(keys, args[0])
+			} else {
+				for key := range records {
+					if g.Match(key) {
+						keys = append(keys, key)
+					}
+				}
+				sort.Strings(keys)
+			}
+
+			for _, key := range keys {
+				record := records[key]
+				gen := *defaults
+				if record.Generator != nil {
+					gen = *record.Generator
+				}
+				applyGeneratorFlags(cmd, &gen)
+
+				if err := record.Rotate(&gen, grace, now); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+
+				results = append(results, rotateOutput{
+					Key:               key,
+					RotatedAt:         record.Previous.RotatedAt,
+					PreviousExpiresAt: record.Previous.ExpiresAt,
+				})
+			}
+
+			return nil
+		}, vault.WithChange("rotate"))
+		if err != nil {
+			if errors.Is(err, vault.ErrKeyNotFound) {
+				errorf("Secret '%s' does not exist in %s.", args[0], v.Path())
+				os.Exit(1)
+			}
+			errorf("Error rotating secrets: %v", err)
+			os.Exit(1)
+		}
+
+		if len(results) == 0 {
+			warnf("No secrets match '%s'.", match)
+		}
+
+		printOutput(cmd, results, func(w io.Writer) {
+			for _, r := range results {
+				if r.PreviousExpiresAt != nil {
+					infof("Rotated %s, the previous secret is valid until %s.", r.Key, formatTime(r.PreviousExpiresAt))
+				} else {
+					infof("Rotated %s.", r.Key)
+				}
+			}
+		}, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "KEY\tROTATED\tPREVIOUS EXPIRES")
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.Key, formatTime(&r.RotatedAt), formatTime(r.PreviousExpiresAt))
+			}
+		})
+		os.Exit(0)
+	},
+}
+
+// rotateOutput reports a rotated secret. The secrets are never included.
+type rotateOutput struct {
+	Key               string     `json:"key" yaml:"key"`
+	RotatedAt         time.Time  `json:"rotated_at" yaml:"rotated_at"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty" yaml:"previous_expires_at,omitempty"`
+}
+
+func init() {
+	addGeneratorFlags(rotateCmd)
+	rotateCmd.Flags().StringP("match", "m", "", "Rotate every secret whose key matches the glob pattern")
+	rotateCmd.Flags().Duration("grace", 24*time.Hour, "How long the previous secret stays valid, 0 keeps it until the next rotation")
+	rootCmd.AddCommand(rotateCmd)
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run -- COMMAND [ARGS...]",
+	Short: "Run a command with the secrets as environment variables",
+	Long: `Run a command with the secrets of the vault injected as environment variables.
+
+The vault is decrypted once and each secret is mapped to an environment
+variable. By default keys are upper cased and any character that is not a
+letter, digit or underscore is replaced with an underscore, so the key
+"db-password" becomes DB_PASSWORD. Use --transform to change this and
+--prefix to prepend a prefix to every name.
+
+Use --only one or more times to limit the secrets to keys matching a glob
+pattern.
+
+Disabled and expired secrets are left out with a warning unless
+--allow-disabled or --allow-expired is used. When --only names such a
+secret exactly, without wildcards, xsops stops before the command is
+started with exit code 3 for disabled or 4 for expired secrets.
+
+Signals received by xsops are forwarded to the command and xsops exits with
+the exit code of the command.`,
+	Example: `xsops run -- ./server
+xsops -v default run --prefix APP_ --only "db-*" -- env
+xsops run --transform none -- printenv my-secret`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		debug, _ := cmd.Flags().GetBool("debug")
+		prefix, _ := cmd.Flags().GetString("prefix")
+		transform, _ := cmd.Flags().GetString("transform")
+		only, _ := cmd.Flags().GetStringArray("only")
+
+		matchers := make([]glob.Glob, 0, len(only))
+		for _, pattern := range only {
+			g, err := vault.CompileGlob(pattern)
+			if err != nil {
+				errorf("Invalid glob pattern %q: %v", pattern, err)
+				os.Exit(1)
+			}
+			matchers = append(matchers, g)
+		}
+
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		records, err := v.All()
+		if err != nil {
+			errorf("Error reading secrets: %v", err)
+			os.Exit(1)
+		}
+
+		selected := map[string]*vault.SecretRecord{}
+		for key, record := range records {
+			if matchAny(matchers, key) {
+				selected[key] = record
+			}
+		}
+		selected = checkLifecycle(cmd, selected, namedKeys(only...), time.Now())
+
+		keys := make([]string, 0, len(selected))
+		for key := range selected {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		names, err := envNames(keys, prefix, transform)
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+
+		environ := os.Environ()
+		for i, key := range keys {
+			if debug {
+				debugf("%s -> %s", key, names[i])
+			}
+			environ = append(environ, names[i]+"="+records[key].Secret)
+		}
+
+		os.Exit(runChild(args, environ))
+	},
+}
+
+// matchAny reports whether key matches one of the matchers. An empty
+// list matches every key.
+func matchAny(matchers []glob.Glob, key string) bool {
+	if len(matchers) == 0 {
+		return true
+	}
+
+	for _, g := range matchers {
+		if g.Match(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// envName converts a vault key into an environment variable name using
+// the transform mode: upper, lower or none.
+func envName(key, prefix, transform string) (string, error) {
+	name := key
+	switch transform {
+	case "upper", "":
+		name = strings.ToUpper(sanitizeEnvName(name))
+	case "lower":
+		name = strings.ToLower(sanitizeEnvName(name))
+	case "none":
+	default:
+		return "", fmt.Errorf("unknown transform '%s', expected upper, lower or none", transform)
+	}
+
+	name = prefix + name
+	if name == "" || strings.ContainsAny(name, "=\x00") {
+		return "", fmt.Errorf("key '%s' cannot be used as an environment variable name", key)
+	}
+
+	return name, nil
+}
+
+// envNames converts keys into environment variable names with envName.
+// It fails when two keys map to the same name.
+func envNames(keys []string, prefix, transform string) ([]string, error) {
+	names := make([]string, len(keys))
+	seen := map[string]string{}
+	for i, key := range keys {
+		name, err := envName(key, prefix, transform)
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("keys '%s' and '%s' both map to the name %s", other, key, name)
+		}
+		seen[name] = key
+		names[i] = name
+	}
+
+	return names, nil
+}
+
+func sanitizeEnvName(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+
+	return sb.String()
+}
+
+// runChild runs args with environ, forwarding signals to the child, and
+// returns the exit code of the child.
+func runChild(args []string, environ []string) int {
+	child := osexec.Command(args[0], args[1:]...)
+	child.Env = environ
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		errorf("Error starting command: %v", err)
+		return 127
+	}
+
+	go func() {
+		for sig := range signals {
+			_ = child.Process.Signal(sig)
+		}
+	}()
+
+	err := child.Wait()
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *osexec.ExitError
+	if !errors.As(err, &exitErr) {
+		errorf("Error running command: %v", err)
+		return 1
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return exitErr.ExitCode()
+}
+
+func init() {
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().String("prefix", "", "Prefix to prepend to every environment variable name")
+	runCmd.Flags().String("transform", "upper", "How keys are converted to names: upper, lower or none")
+	runCmd.Flags().StringArray("only", nil, "Only inject secrets whose key matches the glob pattern (repeatable)")
+	rootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key, prefix, transform string
+		want                   string
+	}{
+		{"db-password", "", "upper", "DB_PASSWORD"},
+		{"db-password", "", "", "DB_PASSWORD"},
+		{"db-password", "", "lower", "db_password"},
+		{"db-password", "", "none", "db-password"},
+		{"db.host", "APP_", "upper", "APP_DB_HOST"},
+		{"1st", "", "upper", "_1ST"},
+		{"a1", "", "upper", "A1"},
+		{"clé", "", "upper", "CL_"},
+		{"app/db/password", "", "upper", "APP_DB_PASSWORD"},
+		{"app/db/password", "X_", "lower", "X_app_db_password"},
+		{"app/db/password", "", "none", "app/db/password"},
+		{"team/1st", "", "upper", "TEAM_1ST"},
+	}
+
+	for _, tt := range tests {
+		got, err := envName(tt.key, tt.prefix, tt.transform)
+		if err != nil || got != tt.want {
+			t.Errorf("envName(%q, %q, %q) = %q, %v, want %q", tt.key, tt.prefix, tt.transform, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ key, transform string }{
+		{"a=b", "none"},
+		{"a", "title"},
+	} {
+		if got, err := envName(tt.key, "", tt.transform); err == nil {
+			t.Errorf("envName(%q, %q) = %q, want an error", tt.key, tt.transform, got)
+		}
+	}
+}
+
+func TestEnvNames(t *testing.T) {
+	names, err := envNames([]string{"app/db/host", "app/db/port", "token"}, "", "upper")
+	if err != nil {
+		t.Fatalf("envNames() error = %v", err)
+	}
+
+	want := []string{"APP_DB_HOST", "APP_DB_PORT", "TOKEN"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("envNames() = %q, want %q", names, want)
+	}
+
+	collisions := [][]string{
+		{"app-db", "app/db"},
+		{"app.db", "app_db"},
+		{"Token", "token"},
+	}
+	for _, keys := range collisions {
+		_, err := envNames(keys, "", "upper")
+		if err == nil || !strings.Contains(err.Error(), keys[0]) || !strings.Contains(err.Error(), keys[1]) {
+			t.Errorf("envNames(%q) error = %v, want a collision", keys, err)
+		}
+	}
+
+	if _, err := envNames([]string{"Token", "token"}, "", "none"); err != nil {
+		t.Errorf("envNames() without a transform error = %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Add, remove and list the tags of secrets",
+	Long: `Add, remove and list the tags of secrets without touching the other tags,
+unlike "xsops set --tags" which replaces all of them.
+
+Tags are written as name=value, or name for a tag without a value. Select
+the secrets with a KEY, or every secret matching --match GLOB or
+--where QUERY; see "xsops ls --help" for the query syntax.`,
+	Example: `xsops tag add db-password env=prod team=platform
+xsops tag add --match "prod-*" env=prod
+xsops tag rm db-password team
+xsops tag ls db-password
+xsops ls --where "env=prod"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add [KEY] TAG...",
+	Short: "Add tags to secrets, replacing the values of existing tags",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateTags(cmd, args, "add", func(record *vault.SecretRecord, tags []string) {
+			if record.Tags == nil {
+				record.Tags = map[string]*string{}
+			}
+
+			for _, tag := range tags {
+				name, value, hasValue := strings.Cut(tag, "=")
+				if hasValue {
+					record.Tags[name] = &value
+				} else {
+					record.Tags[name] = nil
+				}
+			}
+		})
+	},
+}
+
+var tagRmCmd = &cobra.Command{
+	Use:   "rm [KEY] NAME...",
+	Short: "Remove tags from secrets",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateTags(cmd, args, "rm", func(record *vault.SecretRecord, names []string) {
+			for _, name := range names {
+				name, _, _ = strings.Cut(name, "=")
+				delete(record.Tags, name)
+			}
+
+			if len(record.Tags) == 0 {
+				record.Tags = nil
+			}
+		})
+	},
+}
+
+var tagLsCmd = &cobra.Command{
+	Use:   "ls [KEY]",
+	Short: "List the tags of a secret, or every tag in the vault",
+	Long: `List the tags of the secret stored under KEY. Without KEY every tag
+name and value in the vault is listed with the number of secrets that have
+it.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		records, err := v.All()
+		if err != nil {
+			errorf("Error reading secrets: %v", err)
+			os.Exit(1)
+		}
+
+		results := []tagOutput{}
+		if len(args) == 1 {
+			record, ok := records[args[0]]
+			if !ok {
+				errorf("Secret '%s' does not exist in %s.", args[0], v.Path())
+				os.Exit(1)
+			}
+
+			for name, value := range record.Tags {
+				results = append(results, tagOutput{Name: name, Value: value, Count: 1})
+			}
+		} else {
+			counts := map[string]*tagOutput{}
+			for _, record := range records {
+				for name, value := range record.Tags {
+					id := name
+					if value != nil {
+						id += "=" + *value
+					}
+
+					if counts[id] == nil {
+						counts[id] = &tagOutput{Name: name, Value: value}
+					}
+					counts[id].Count++
+				}
+			}
+
+			for _, t := range counts {
+				results = append(results, *t)
+			}
+		}
+
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].String() < results[j].String()
+		})
+
+		printOutput(cmd, results, func(w io.Writer) {
+			for _, t := range results {
+				fmt.Fprintln(w, t.String())
+			}
+		}, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "NAME\tVALUE\tSECRETS")
+			for _, t := range results {
+				value := "-"
+				if t.Value != nil {
+					value = *t.Value
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\n", t.Name, value, t.Count)
+			}
+		})
+		os.Exit(0)
+	},
+}
+
+// tagOutput is a tag as emitted by tag ls. Count is the number of
+// secrets with the tag.
+type tagOutput struct {
+	Name  string  `json:"name" yaml:"name"`
+	Value *string `json:"value,omitempty" yaml:"value,omitempty"`
+	Count int     `json:"count" yaml:"count"`
+}
+
+// String formats the tag as name=value, or name without a value.
+func (t tagOutput) String() string {
+	if t.Value == nil {
+		return t.Name
+	}
+
+	return t.Name + "=" + *t.Value
+}
+
+// updateTags applies fn with the tags in args to the secrets selected by
+// the first argument or the --match and --where flags of cmd in a single
+// write, then exits.
+func updateTags(cmd *cobra.Command, args []string, op string, fn func(record *vault.SecretRecord, tags []string)) {
+	match, _ := cmd.Flags().GetString("match")
+	where := compileWhere(cmd)
+
+	var g glob.Glob
+	if match != "" {
+		var err error
+		if g, err = vault.CompileGlob(match); err != nil {
+			errorf("Invalid --match pattern '%s': %v", match, err)
+			os.Exit(1)
+		}
+	}
+
+	key, tags := "", args
+	if g == nil && where == nil {
+		key, tags = args[0], args[1:]
+	}
+
+	if len(tags) == 0 {
+		errorf("You must provide at least one tag.")
+		usagef("Usage: xsops tag %s [KEY | --match GLOB | --where QUERY] TAG...", op)
+		os.Exit(1)
+	}
+
+	for _, tag := range tags {
+		if name, _, _ := strings.Cut(tag, "="); name == "" {
+			errorf("Invalid tag '%s', expected name=value or name.", tag)
+			os.Exit(1)
+		}
+	}
+
+	v, err := openVault(cmd)
+	if err != nil {
+		errorf("Error opening vault: %v", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	results := []recordOutput{}
+	err = v.Update(func(records map[string]*vault.SecretRecord) error {
+		keys := []string{}
+		if key != "" {
+			if _, ok := records[key]; !ok {
+				return vault.ErrKeyNotFound
+			}
+			keys = append(keys, key)
+		} else {
+			for k, record := range records {
+				if (g == nil || g.Match(k)) && (where == nil || where.Match(k, record, now)) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+		}
+
+		for _, k := range keys {
+			fn(records[k], tags)
+			results = append(results, newRecordOutput(k, records[k], false))
+		}
+
+		return nil
+	}, vault.WithChange("tag"))
+	if err != nil {
+		if errors.Is(err, vault.ErrKeyNotFound) {
+			errorf("Secret '%s' does not exist in %s.", key, v.Path())
+			os.Exit(1)
+		}
+		errorf("Error updating tags: %v", err)
+		os.Exit(1)
+	}
+
+	if len(results) == 0 {
+		warnf("No secrets match.")
+	}
+
+	printOutput(cmd, results, func(w io.Writer) {
+		for _, r := range results {
+			infof("%s: %s", r.Key, formatTags(r.Tags))
+		}
+	}, func(w *tabwriter.Writer) {
+		writeRecordTable(w, results)
+	})
+	os.Exit(0)
+}
+
+func init() {
+	for _, c := range []*cobra.Command{tagAddCmd, tagRmCmd} {
+		c.Flags().StringP("match", "m", "", "Change every secret whose key matches the glob pattern")
+		c.Flags().StringP("where", "w", "", "Change every secret matching the query, see \"xsops ls --help\"")
+	}
+
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRmCmd)
+	tagCmd.AddCommand(tagLsCmd)
+	rootCmd.AddCommand(tagCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Revert the last change to the vault",
+	Long: `Revert the last change to the vault by restoring its newest backup.
+
+Before every change xsops keeps the encrypted vault as a backup in the data
+home directory. Undo restores the newest backup and removes it, so running
+undo again reverts the change before that. Use "xsops backups ls" to list
+the backups and "xsops backups restore" to restore a specific one.`,
+	Example: `xsops undo
+xsops -v default undo`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := openVault(cmd)
+		if err != nil {
+			errorf("Error opening vault: %v", err)
+			os.Exit(1)
+		}
+
+		b, err := v.Undo()
+		if err != nil {
+			if errors.Is(err, vault.ErrNoBackup) {
+				warnf("There is nothing to undo for %s.", v.Path())
+				os.Exit(1)
+			}
+
+			errorf("Error undoing the last change: %v", err)
+			os.Exit(1)
+		}
+
+		printBackupRestored(cmd, b)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
@@ -5,20 +5,17 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"time"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/hyprxlabs/xsops/agent"
 	"github.com/hyprxlabs/xsops/internal/config"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-type SecretRecord struct {
-	Secret    string             `json:"secret"`
-	ExpiresAt *time.Time         `json:"expires_at,omitempty"`
-	Tags      map[string]*string `json:"tags,omitempty"`
-	Enabled   bool               `json:"enabled"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-}
-
 func getUserHomeData() (string, error) {
 
 	homeData := os.Getenv("XSOPS_DATA_HOME")
@@ -59,33 +56,42 @@ func getUserHomeData() (string, error) {
 }
 
 func getFilePath(uriString string) (string, error) {
+	filePath, _, err := resolveVault(uriString)
+	return filePath, err
+}
+
+// resolveVault returns the path of the vault named by uriString along
+// with its registry entry, which is nil unless uriString is the name of
+// a vault registered with "xsops vault add".
+func resolveVault(uriString string) (string, *config.VaultEntry, error) {
 	if uriString == "default" || uriString == "" {
 		dir, err := getUserHomeData()
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
 
-		return filepath.Join(dir, "xsops.secrets.json"), nil
+		return filepath.Join(dir, "xsops.secrets.json"), nil, nil
 	}
 
 	if uriString == "." {
 		dir, err := os.Getwd()
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
-		return filepath.Join(dir, "xsops.secrets.json"), nil
+		return filepath.Join(dir, "xsops.secrets.json"), nil, nil
 	}
 
-	db, err := config.GetRegistry()
+	registry, err := config.LoadRegistry()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	filePath := db.GetString(uriString)
-	if filePath != "" {
-		return filePath, nil
+	if entry, ok := registry.Get(uriString); ok {
+		filePath, err := expandPath(entry.Path)
+		return filePath, entry, err
 	}
 
+	filePath := ""
 	uri, err := url.Parse(uriString)
 	if err == nil {
 		if (uri.Scheme == "file" || uri.Scheme == "xsops") && uri.Path != "" {
@@ -98,10 +104,228 @@ func getFilePath(uriString string) (string, error) {
 		if !filepath.IsAbs(filePath) {
 			filePath, err = filepath.Abs(filePath)
 			if err != nil {
-				return "", err
+				return "", nil, err
 			}
 		}
 	}
 
-	return filePath, nil
+	return filePath, nil, nil
+}
+
+// expandPath expands a leading ~ to the home directory and makes path
+// absolute.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	return filepath.Abs(path)
+}
+
+// defaultVault is the vault used when neither --vault, XSOPS_VAULT nor
+// the vault key of config.toml is set.
+const defaultVault = "./xsops.secrets.json"
+
+var (
+	configOnce sync.Once
+	configFile *viper.Viper
+	configErr  error
+)
+
+// getConfig returns config.toml, which is read once per process.
+func getConfig() (*viper.Viper, error) {
+	configOnce.Do(func() {
+		configFile, configErr = config.GetConfig()
+	})
+
+	return configFile, configErr
+}
+
+// getSetting returns the value of the config.toml key for the vault
+// named vaultName, see config.Lookup for the order in which the
+// environment and config.toml are consulted.
+func getSetting(vaultName, key string) (string, bool) {
+	cfg, err := getConfig()
+	if err != nil {
+		warnf("Error reading config.toml: %v", err)
+	}
+
+	value, _, ok := config.Lookup(cfg, vaultName, key)
+	return value, ok
+}
+
+// getVaultName returns the vault selected for cmd, see selectVault.
+func getVaultName(cmd *cobra.Command) string {
+	name, _ := selectVault(cmd)
+	return name
+}
+
+// selectVault returns the vault selected by the --vault flag of cmd,
+// XSOPS_VAULT, the active context or the vault key of config.toml, in
+// that order, along with a description of where it came from.
+func selectVault(cmd *cobra.Command) (name string, source string) {
+	if cmd.Flags().Changed("vault") {
+		name, _ := cmd.Flags().GetString("vault")
+		return name, "--vault"
+	}
+
+	// An empty XSOPS_VAULT is honoured like every XSOPS_* override and
+	// selects the default vault in the data home, like --vault "".
+	if name, ok := os.LookupEnv("XSOPS_VAULT"); ok {
+		return name, "XSOPS_VAULT"
+	}
+
+	context, err := config.GetContext()
+	if err != nil {
+		warnf("Error reading the active context: %v", err)
+	}
+	if context != "" {
+		return context, "context"
+	}
+
+	cfg, err := getConfig()
+	if err != nil {
+		warnf("Error reading config.toml: %v", err)
+	}
+	if cfg != nil && cfg.GetString("vault") != "" {
+		return cfg.GetString("vault"), "config.toml"
+	}
+
+	return defaultVault, "default"
+}
+
+// getBackend returns the backend used by commands for the vault named
+// vaultName. When XSOPS_AGENT_SOCK is set the vaults are decrypted by the
+// agent listening on that socket, falling back to the local backend when
+// the agent is not running.
+func getBackend(vaultName string) (vault.Backend, error) {
+	backend, err := getLocalBackend(vaultName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if socket := os.Getenv(agent.DefaultSocketEnv); socket != "" {
+		return agent.NewClient(socket, backend), nil
+	}
+
+	return backend, nil
+}
+
+// getLocalBackend returns the backend named by the XSOPS_BACKEND
+// environment variable or the backend key of config.toml for the vault
+// named vaultName, defaulting to the in-process sops backend. Sops
+// backends encrypt new vaults for recipients when any are given.
+func getLocalBackend(vaultName string, recipients []string) (vault.Backend, error) {
+	name, _ := getSetting(vaultName, "backend")
+	backend, err := vault.NewBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b := backend.(type) {
+	case *vault.SopsBackend:
+		b.Recipients = recipients
+	case *vault.ExecBackend:
+		b.Recipients = recipients
+		b.Binary, _ = getSetting(vaultName, "sops_binary")
+	}
+
+	return backend, nil
+}
+
+// openVault resolves the vault selected for cmd and opens it. Vaults
+// registered as read-only are opened read-only.
+func openVault(cmd *cobra.Command) (*vault.Vault, error) {
+	return openNamedVault(getVaultName(cmd))
+}
+
+// openNamedVault opens the vault named name, which is resolved like the
+// --vault flag.
+func openNamedVault(name string) (*vault.Vault, error) {
+	filePath, entry, err := resolveVault(name)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := getBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []vault.Option{vault.WithBackend(backend), vault.WithHistory(getHistoryCount(name))}
+	if dir, err := getBackupDir(); err == nil {
+		opts = append(opts, vault.WithBackups(dir, getBackupCount(name)))
+	}
+
+	if entry != nil && entry.ReadOnly {
+		opts = append(opts, vault.WithReadOnly(true))
+	}
+
+	return vault.Open(filePath, opts...)
+}
+
+// getBackupDir returns the directory below the user's data home where
+// vault backups are kept.
+func getBackupDir() (string, error) {
+	homeData, err := getUserHomeData()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeData, "backups"), nil
+}
+
+// getBackupCount returns the number of backups kept for the vault named
+// vaultName from the backups key of config.toml, defaulting to
+// vault.DefaultBackups.
+func getBackupCount(vaultName string) int {
+	return getIntSetting(vaultName, "backups", vault.DefaultBackups)
+}
+
+// getHistoryCount returns the number of prior versions kept in each
+// secret of the vault named vaultName.
+func getHistoryCount(vaultName string) int {
+	return getIntSetting(vaultName, "history", vault.DefaultHistory)
+}
+
+// getIntSetting returns the number set for key, or def when it is not
+// set, empty or not a number.
+func getIntSetting(vaultName, key string, def int) int {
+	value, ok := getSetting(vaultName, key)
+	if !ok || value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		warnf("Ignoring %s = '%s' in config.toml, expected a number", key, value)
+		return def
+	}
+
+	return n
+}
+
+// applyAgeKeyFile points sops at the age_key_file of config.toml for the
+// vault named vaultName unless SOPS_AGE_KEY_FILE is already set.
+func applyAgeKeyFile(vaultName string) {
+	if os.Getenv("SOPS_AGE_KEY_FILE") != "" {
+		return
+	}
+
+	file, ok := getSetting(vaultName, "age_key_file")
+	if !ok || file == "" {
+		return
+	}
+
+	path, err := expandPath(file)
+	if err != nil {
+		warnf("Ignoring age_key_file '%s': %v", file, err)
+		return
+	}
+
+	os.Setenv("SOPS_AGE_KEY_FILE", path)
 }
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/hyprxlabs/xsops/internal/config"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Manage the registry of named vaults",
+	Long: `Manage the registry of named vaults.
+
+Registered vaults can be used by name with the --vault flag, for example
+"xsops -v prod get db-password". The registry is stored as registry.json in
+the config home, for example ~/.config/xsops/registry.json on Linux.
+
+Each vault has a path, an optional description, the age recipients that new
+vaults are encrypted for by "xsops init" and a read-only flag that makes
+xsops refuse to change the vault.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var vaultAddCmd = &cobra.Command{
+	Use:   "add NAME PATH",
+	Short: "Register a named vault",
+	Long: `Register a named vault stored at PATH.
+
+Relative paths are made absolute; paths starting with ~ are kept so the
+registry can be shared between users. Use --force to replace a vault that
+is already registered.`,
+	Example: `xsops vault add prod ~/secrets/prod.secrets.json --description "Production"
+xsops vault add shared /srv/secrets/xsops.secrets.json --read-only
+xsops vault add team ./team.secrets.json -r age1... -r age1...
+xsops -v team init`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, path := args[0], args[1]
+		if err := checkVaultName(name); err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+
+		recipients, _ := cmd.Flags().GetStringSlice("recipient")
+		for _, r := range recipients {
+			if _, err := sopsage.MasterKeyFromRecipient(r); err != nil {
+				errorf("Invalid recipient '%s': %v", r, err)
+				os.Exit(1)
+			}
+		}
+
+		if !strings.HasPrefix(path, "~") {
+			abs, err := expandPath(path)
+			if err != nil {
+				errorf("Error resolving path: %v", err)
+				os.Exit(1)
+			}
+			path = abs
+		}
+
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			errorf("Error reading the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		if _, ok := registry.Get(name); ok && !force {
+			errorf("Vault '%s' is already registered, use --force to replace it.", name)
+			os.Exit(1)
+		}
+
+		description, _ := cmd.Flags().GetString("description")
+		readOnly, _ := cmd.Flags().GetBool("read-only")
+		entry := &config.VaultEntry{
+			Name:        name,
+			Path:        path,
+			Description: description,
+			Recipients:  recipients,
+			ReadOnly:    readOnly,
+		}
+		registry.Set(entry)
+
+		if err := registry.Save(); err != nil {
+			errorf("Error writing the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		result := newVaultOutput(entry, false)
+		printOutput(cmd, result, func(w io.Writer) {
+			infof("Registered vault '%s' at %s.", name, path)
+		}, func(w *tabwriter.Writer) {
+			writeVaultTable(w, []vaultOutput{result})
+		})
+		os.Exit(0)
+	},
+}
+
+var vaultLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the registered vaults",
+	Long: `List the registered vaults sorted by name along with whether each
+vault exists and whether it can be decrypted with the keys available to you.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			errorf("Error reading the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		entries := registry.Entries()
+		results := make([]vaultOutput, 0, len(entries))
+		for _, entry := range entries {
+			results = append(results, newVaultOutput(entry, true))
+		}
+
+		printOutput(cmd, results, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			writeVaultTable(tw, results)
+			tw.Flush()
+		}, func(w *tabwriter.Writer) {
+			writeVaultTable(w, results)
+		})
+		os.Exit(0)
+	},
+}
+
+var vaultRmCmd = &cobra.Command{
+	Use:   "rm NAME",
+	Short: "Remove a vault from the registry",
+	Long: `Remove a vault from the registry. The vault file itself is not
+deleted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			errorf("Error reading the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		if !registry.Remove(args[0]) {
+			errorf("Vault '%s' is not registered.", args[0])
+			os.Exit(1)
+		}
+
+		if err := registry.Save(); err != nil {
+			errorf("Error writing the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		result := vaultRmOutput{Name: args[0], Removed: true}
+		printOutput(cmd, result, func(w io.Writer) {
+			infof("Removed vault '%s' from the registry.", args[0])
+		}, nil)
+		os.Exit(0)
+	},
+}
+
+var vaultShowCmd = &cobra.Command{
+	Use:   "show NAME",
+	Short: "Show a registered vault",
+	Long: `Show a registered vault, including whether it exists and whether it
+can be decrypted with the keys available to you.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := config.LoadRegistry()
+		if err != nil {
+			errorf("Error reading the vault registry: %v", err)
+			os.Exit(1)
+		}
+
+		entry, ok := registry.Get(args[0])
+		if !ok {
+			errorf("Vault '%s' is not registered.", args[0])
+			os.Exit(1)
+		}
+
+		result := newVaultOutput(entry, true)
+		printOutput(cmd, result, func(w io.Writer) {
+			recipients := "-"
+			if len(result.Recipients) > 0 {
+				recipients = strings.Join(result.Recipients, ",")
+			}
+
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "Name:\t%s\n", result.Name)
+			fmt.Fprintf(tw, "Path:\t%s\n", result.Path)
+			fmt.Fprintf(tw, "Description:\t%s\n", result.Description)
+			fmt.Fprintf(tw, "Recipients:\t%s\n", recipients)
+			fmt.Fprintf(tw, "Read-only:\t%t\n", result.ReadOnly)
+			fmt.Fprintf(tw, "Exists:\t%t\n", result.Exists)
+			fmt.Fprintf(tw, "Decryptable:\t%t\n", result.Decryptable)
+			if result.Error != "" {
+				fmt.Fprintf(tw, "Error:\t%s\n", result.Error)
+			}
+			tw.Flush()
+		}, func(w *tabwriter.Writer) {
+			writeVaultTable(w, []vaultOutput{result})
+		})
+		os.Exit(0)
+	},
+}
+
+// vaultOutput is a registered vault as emitted by the vault commands.
+type vaultOutput struct {
+	Name        string   `json:"name" yaml:"name"`
+	Path        string   `json:"path" yaml:"path"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Recipients  []string `json:"recipients,omitempty" yaml:"recipients,omitempty"`
+	ReadOnly    bool     `json:"read_only" yaml:"read_only"`
+	Exists      bool     `json:"exists" yaml:"exists"`
+	Decryptable bool     `json:"decryptable" yaml:"decryptable"`
+	Error       string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// newVaultOutput describes entry. When check is true the vault is opened
+// and decrypted to report whether it exists and can be decrypted.
+func newVaultOutput(entry *config.VaultEntry, check bool) vaultOutput {
+	out := vaultOutput{
+		Name:        entry.Name,
+		Path:        entry.Path,
+		Description: entry.Description,
+		Recipients:  entry.Recipients,
+		ReadOnly:    entry.ReadOnly,
+	}
+
+	if !check {
+		return out
+	}
+
+	path, err := expandPath(entry.Path)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+
+	backend, err := getBackend(entry.Name)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+
+	v, err := vault.Open(path, vault.WithBackend(backend), vault.WithReadOnly(true))
+	if err != nil {
+		if !errors.Is(err, vault.ErrVaultNotFound) {
+			out.Error = err.Error()
+		}
+		return out
+	}
+	out.Exists = true
+
+	if _, err := v.List(); err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Decryptable = true
+
+	return out
+}
+
+type vaultRmOutput struct {
+	Name    string `json:"name" yaml:"name"`
+	Removed bool   `json:"removed" yaml:"removed"`
+}
+
+func writeVaultTable(w *tabwriter.Writer, vaults []vaultOutput) {
+	fmt.Fprintln(w, "NAME\tPATH\tREAD-ONLY\tEXISTS\tDECRYPTABLE\tDESCRIPTION")
+	for _, v := range vaults {
+		description := v.Description
+		if description == "" {
+			description = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%t\t%s\n", v.Name, v.Path, v.ReadOnly, v.Exists, v.Decryptable, description)
+	}
+}
+
+// checkVaultName returns an error when name cannot be used for a
+// registered vault because --vault would resolve it to something else,
+// or because its [vaults.NAME] table in config.toml would be split on
+// the dots of the name.
+func checkVaultName(name string) error {
+	switch {
+	case name == "", name == "default":
+		return fmt.Errorf("'%s' is reserved and cannot be used as a vault name", name)
+	case strings.ContainsAny(name, `/\:`), strings.HasPrefix(name, "~"):
+		return fmt.Errorf("vault name '%s' must not look like a path or URI", name)
+	case strings.Contains(name, "."):
+		return fmt.Errorf("vault name '%s' must not contain '.'", name)
+	}
+
+	return nil
+}
+
+func init() {
+	vaultAddCmd.Flags().String("description", "", "Description of the vault")
+	vaultAddCmd.Flags().StringSliceP("recipient", "r", nil, "Age recipient new vaults are encrypted for, can be repeated")
+	vaultAddCmd.Flags().Bool("read-only", false, "Refuse to change the vault")
+	vaultAddCmd.Flags().BoolP("force", "f", false, "Replace a vault that is already registered")
+
+	vaultCmd.AddCommand(vaultAddCmd)
+	vaultCmd.AddCommand(vaultLsCmd)
+	vaultCmd.AddCommand(vaultRmCmd)
+	vaultCmd.AddCommand(vaultShowCmd)
+	rootCmd.AddCommand(vaultCmd)
+}
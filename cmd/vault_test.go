@@ -0,0 +1,27 @@
+package cmd
+
+import "testing"
+
+func TestCheckVaultName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"prod", true},
+		{"team-a_1", true},
+		{"", false},
+		{"default", false},
+		{".", false},
+		{"prod.eu", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"sops:x", false},
+		{"~prod", false},
+	}
+
+	for _, tt := range tests {
+		if err := checkVaultName(tt.name); (err == nil) != tt.ok {
+			t.Errorf("checkVaultName(%q) error = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
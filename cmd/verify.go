@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyprxlabs/xsops/manifest"
+	"github.com/hyprxlabs/xsops/vault"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify that the secrets declared in xsops.yaml exist",
+	Long: `Verify that the secrets declared in the xsops.yaml manifest exist in
+the vault of every environment and are neither disabled nor expired.
+
+The manifest is read from --manifest or from xsops.yaml in the current
+directory or one of its parents. Use --env to verify only some of the
+environments. When the manifest declares no environments the vault selected
+with --vault is verified.
+
+The exit code is 1 when a required secret is missing, disabled or expired.
+Secrets declared with "required: false" are reported but do not fail.`,
+	Example: `xsops verify
+xsops verify --env prod -o json
+xsops verify --manifest deploy/xsops.yaml`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := loadManifest(cmd)
+		targets := manifestTargets(cmd, m)
+
+		now := time.Now()
+		results := []verifyOutput{}
+		failed := false
+		for _, t := range targets {
+			records := map[string]*vault.SecretRecord{}
+			v, err := openNamedVault(t.Vault)
+			if err == nil {
+				records, err = v.All()
+			}
+
+			if err != nil && !errors.Is(err, vault.ErrVaultNotFound) {
+				errorf("Error reading vault %s: %v", t.Vault, err)
+				os.Exit(1)
+			}
+
+			if errors.Is(err, vault.ErrVaultNotFound) {
+				warnf("The vault %s of environment '%s' does not exist.", t.Vault, displayEnv(t.Env))
+			}
+
+			for _, r := range m.Verify(records, now) {
+				failed = failed || r.Failed()
+				results = append(results, verifyOutput{Env: t.Env, Vault: t.Vault, Result: r})
+			}
+		}
+
+		writeTable := func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "ENV\tKEY\tSTATUS\tREQUIRED")
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", displayEnv(r.Env), r.Key, r.Status, r.Required)
+			}
+		}
+
+		printOutput(cmd, results, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			writeTable(tw)
+			tw.Flush()
+		}, writeTable)
+
+		if failed {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	},
+}
+
+// verifyOutput is the state of a declared secret in the vault of an
+// environment.
+type verifyOutput struct {
+	Env             string `json:"env,omitempty" yaml:"env,omitempty"`
+	Vault           string `json:"vault" yaml:"vault"`
+	manifest.Result `yaml:",inline"`
+}
+
+// manifestTarget is an environment of a manifest and its vault.
+type manifestTarget struct {
+	Env   string
+	Vault string
+}
+
+// loadManifest loads the manifest from the --manifest flag of cmd or
+// finds xsops.yaml in the current directory or its parents, exiting when
+// there is none.
+func loadManifest(cmd *cobra.Command) *manifest.Manifest {
+	path, _ := cmd.Flags().GetString("manifest")
+	if path == "" || path == manifest.FileName {
+		found, err := manifest.Find(".")
+		if err != nil {
+			errorf("No %s found in the current directory or its parents.", manifest.FileName)
+			os.Exit(1)
+		}
+		path = found
+	}
+
+	m, err := manifest.Load(path)
+	if err != nil {
+		errorf("Error reading manifest: %v", err)
+		os.Exit(1)
+	}
+
+	return m
+}
+
+// manifestTargets returns the environments of m selected by the --env
+// flag of cmd along with their vaults. Relative vault paths are resolved
+// against the directory of the manifest. Without environments the vault
+// selected for cmd is the only target.
+func manifestTargets(cmd *cobra.Command, m *manifest.Manifest) []manifestTarget {
+	envs, _ := cmd.Flags().GetStringSlice("env")
+	if len(m.Environments) == 0 {
+		if len(envs) > 0 {
+			errorf("%s declares no environments.", m.Path)
+			os.Exit(1)
+		}
+		return []manifestTarget{{Vault: getVaultName(cmd)}}
+	}
+
+	for _, env := range envs {
+		if _, ok := m.Environments[env]; !ok {
+			errorf("Environment '%s' is not declared in %s.", env, m.Path)
+			os.Exit(1)
+		}
+	}
+
+	targets := []manifestTarget{}
+	for _, name := range m.EnvironmentNames() {
+		if len(envs) > 0 && !slices.Contains(envs, name) {
+			continue
+		}
+
+		ref := m.Environments[name].Vault
+		switch {
+		case ref == ".":
+			ref = filepath.Join(m.Dir(), "xsops.secrets.json")
+		case isRelativeVaultPath(ref):
+			ref = filepath.Join(m.Dir(), ref)
+		}
+		targets = append(targets, manifestTarget{Env: name, Vault: ref})
+	}
+
+	return targets
+}
+
+// isRelativeVaultPath reports whether ref is a relative file path rather
+// than a URI or the name of a registered vault.
+func isRelativeVaultPath(ref string) bool {
+	if strings.Contains(ref, "://") || filepath.IsAbs(ref) {
+		return false
+	}
+
+	return strings.ContainsAny(ref, `/\`) || filepath.Ext(ref) != ""
+}
+
+// displayEnv returns env for tables, where the vault selected with
+// --vault has no environment name.
+func displayEnv(env string) string {
+	if env == "" {
+		return "-"
+	}
+
+	return env
+}
+
+func init() {
+	verifyCmd.Flags().StringP("manifest", "m", "", "Path of the manifest, defaults to the closest "+manifest.FileName)
+	verifyCmd.Flags().StringSlice("env", nil, "Environments to verify, defaults to all")
+	rootCmd.AddCommand(verifyCmd)
+}
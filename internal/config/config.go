@@ -8,30 +8,6 @@ import (
 	"github.com/spf13/viper"
 )
 
-func GetRegistry() (*viper.Viper, error) {
-
-	homeConfig, err := GetHomeConfig()
-	if err != nil {
-		return nil, err
-	}
-
-	v1 := viper.New()
-	v1.SetConfigName("registry")
-	v1.SetConfigType("json")
-	v1.AddConfigPath(homeConfig)
-
-	err = v1.ReadInConfig()
-	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Config file not found; ignore error if desired
-			return v1, nil
-		}
-		return nil, err
-	}
-
-	return v1, nil
-}
-
 func GetHomeConfig() (string, error) {
 	homeConfig := os.Getenv("XSOPS_CONFIG_HOME")
 	if homeConfig != "" {
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// contextPath returns the path of the file holding the active context.
+func contextPath() (string, error) {
+	homeConfig, err := GetHomeConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeConfig, "context"), nil
+}
+
+// GetContext returns the name of the active vault set with SetContext,
+// or an empty string when no context is active.
+func GetContext() (string, error) {
+	path, err := contextPath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetContext makes name the active vault. An empty name clears the
+// active context.
+func SetContext(name string) error {
+	path, err := contextPath()
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(name+"\n"), 0600)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// VaultEntry is a named vault in registry.json.
+type VaultEntry struct {
+	Name        string   `json:"-" yaml:"name"`
+	Path        string   `json:"path" yaml:"path"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Recipients  []string `json:"recipients,omitempty" yaml:"recipients,omitempty"`
+	ReadOnly    bool     `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+}
+
+// Registry maps vault names to their entries. It is stored as
+// registry.json in the config home. Entries may also be plain strings
+// holding only the path of the vault.
+type Registry struct {
+	path    string
+	entries map[string]*VaultEntry
+}
+
+// LoadRegistry reads registry.json from the config home. A missing file
+// yields an empty registry.
+func LoadRegistry() (*Registry, error) {
+	homeConfig, err := GetHomeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{
+		path:    filepath.Join(homeConfig, "registry.json"),
+		entries: map[string]*VaultEntry{},
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return r, nil
+		}
+		return nil, err
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	for name, value := range raw {
+		entry := &VaultEntry{}
+		var path string
+		if err := json.Unmarshal(value, &path); err == nil {
+			entry.Path = path
+		} else if err := json.Unmarshal(value, entry); err != nil {
+			return nil, err
+		}
+
+		entry.Name = name
+		r.entries[name] = entry
+	}
+
+	return r, nil
+}
+
+// Path returns the path of registry.json.
+func (r *Registry) Path() string {
+	return r.path
+}
+
+// Get returns the entry registered under name.
+func (r *Registry) Get(name string) (*VaultEntry, bool) {
+	entry, ok := r.entries[name]
+	return entry, ok
+}
+
+// Set registers entry under its name, replacing any existing entry.
+func (r *Registry) Set(entry *VaultEntry) {
+	r.entries[entry.Name] = entry
+}
+
+// Remove removes the entry registered under name and reports whether it
+// existed.
+func (r *Registry) Remove(name string) bool {
+	_, ok := r.entries[name]
+	delete(r.entries, name)
+	return ok
+}
+
+// Entries returns the registered entries sorted by name.
+func (r *Registry) Entries() []*VaultEntry {
+	entries := make([]*VaultEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
+
+// Save writes the registry to registry.json, replacing the file
+// atomically.
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".registry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), r.path)
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XSOPS_CONFIG_HOME", dir)
+
+	legacy := `{"old": "/srv/old.json", "new": {"path": "~/new.json", "read_only": true}}`
+	if err := os.WriteFile(filepath.Join(dir, "registry.json"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := LoadRegistry()
+	if err != nil {
+		t.Fatalf("LoadRegistry() error = %v", err)
+	}
+
+	if entry, ok := r.Get("old"); !ok || entry.Path != "/srv/old.json" {
+		t.Errorf("Get(old) = %v, %v, want the path of the string entry", entry, ok)
+	}
+
+	if entry, ok := r.Get("new"); !ok || entry.Path != "~/new.json" || !entry.ReadOnly {
+		t.Errorf("Get(new) = %v, %v, want a read-only entry", entry, ok)
+	}
+
+	r.Set(&VaultEntry{Name: "prod", Path: "/srv/prod.json", Recipients: []string{"age1abc"}})
+	if !r.Remove("old") {
+		t.Error("Remove(old) = false, want true")
+	}
+
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	r, err = LoadRegistry()
+	if err != nil {
+		t.Fatalf("LoadRegistry() error = %v", err)
+	}
+
+	names := []string{}
+	for _, entry := range r.Entries() {
+		names = append(names, entry.Name)
+	}
+
+	if want := []string{"new", "prod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Entries() = %v, want %v", names, want)
+	}
+
+	if entry, _ := r.Get("prod"); !reflect.DeepEqual(entry.Recipients, []string{"age1abc"}) {
+		t.Errorf("Get(prod).Recipients = %v, want [age1abc]", entry.Recipients)
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Setenv("XSOPS_CONFIG_HOME", t.TempDir())
+
+	if name, err := GetContext(); err != nil || name != "" {
+		t.Errorf("GetContext() = %q, %v, want no context", name, err)
+	}
+
+	if err := SetContext("prod"); err != nil {
+		t.Fatalf("SetContext() error = %v", err)
+	}
+
+	if name, err := GetContext(); err != nil || name != "prod" {
+		t.Errorf("GetContext() = %q, %v, want prod", name, err)
+	}
+
+	if err := SetContext(""); err != nil {
+		t.Fatalf("SetContext(\"\") error = %v", err)
+	}
+
+	if name, _ := GetContext(); name != "" {
+		t.Errorf("GetContext() after clearing = %q, want no context", name)
+	}
+}
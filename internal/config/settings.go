@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Setting is a key of config.toml.
+type Setting struct {
+	// Key is the dotted name of the setting, such as ensure.size.
+	Key string
+
+	// Type is the type of the value: string or int.
+	Type string
+
+	// Description explains the setting for "xsops config list".
+	Description string
+
+	// PerVault reports whether the setting can be overridden for a
+	// single vault in a [vaults.NAME] table.
+	PerVault bool
+}
+
+// Settings are the keys that config.toml supports.
+var Settings = []Setting{
+	{Key: "vault", Type: "string", Description: "Vault used when no --vault, XSOPS_VAULT or context is set"},
+	{Key: "backend", Type: "string", Description: "Backend used to encrypt vaults: sops, sops-exec or plaintext", PerVault: true},
+	{Key: "sops_binary", Type: "string", Description: "Path of the sops binary used by the sops-exec backend", PerVault: true},
+	{Key: "age_key_file", Type: "string", Description: "Age identity file used to decrypt vaults", PerVault: true},
+	{Key: "editor", Type: "string", Description: "Editor used by edit", PerVault: true},
+	{Key: "output", Type: "string", Description: "Output format: text, table, json or yaml", PerVault: true},
+	{Key: "backups", Type: "int", Description: "Number of backups kept for each vault, 0 disables backups", PerVault: true},
+	{Key: "history", Type: "int", Description: "Number of prior versions kept in each secret, 0 disables the history", PerVault: true},
+	{Key: "ensure.size", Type: "int", Description: "Size of secrets generated by ensure", PerVault: true},
+	{Key: "ensure.symbols", Type: "string", Description: "Symbols included in secrets generated by ensure, empty for none", PerVault: true},
+	{Key: "ensure.chars", Type: "string", Description: "Characters secrets generated by ensure are made of", PerVault: true},
+}
+
+// LookupSetting returns the setting for key. Keys of the form
+// vaults.NAME.KEY return the setting KEY along with the vault NAME.
+func LookupSetting(key string) (setting *Setting, vaultName string, err error) {
+	key = strings.ToLower(key)
+	if rest, ok := strings.CutPrefix(key, "vaults."); ok {
+		name, sub, ok := strings.Cut(rest, ".")
+		if !ok || name == "" {
+			return nil, "", fmt.Errorf("expected vaults.NAME.KEY, got '%s'", key)
+		}
+
+		s, _, err := LookupSetting(sub)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !s.PerVault {
+			return nil, "", fmt.Errorf("'%s' cannot be set for a single vault", sub)
+		}
+
+		return s, name, nil
+	}
+
+	for i := range Settings {
+		if Settings[i].Key == key {
+			return &Settings[i], "", nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("unknown config key '%s'", key)
+}
+
+// Parse converts value to the type of the setting.
+func (s *Setting) Parse(value string) (any, error) {
+	switch s.Type {
+	case "int":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s expects a number, got '%s'", s.Key, value)
+		}
+		return n, nil
+	default:
+		return value, nil
+	}
+}
+
+// EnvName returns the environment variable that overrides key, such as
+// XSOPS_ENSURE_SIZE for ensure.size.
+func EnvName(key string) string {
+	return "XSOPS_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// Lookup returns the value of key and where it came from. Values are
+// taken, in order, from the XSOPS_* environment variable of the key when
+// it is set, even to an empty value, the [vaults.NAME] table of vaultName
+// in cfg and the top level of cfg. The source is the environment variable
+// or the config key that was used.
+func Lookup(cfg *viper.Viper, vaultName, key string) (value string, source string, ok bool) {
+	env := EnvName(key)
+	if value, ok := os.LookupEnv(env); ok {
+		return value, env, true
+	}
+
+	if cfg == nil {
+		return "", "", false
+	}
+
+	if vaultName != "" {
+		vaultKey := "vaults." + vaultName + "." + key
+		if cfg.IsSet(vaultKey) {
+			return cfg.GetString(vaultKey), vaultKey, true
+		}
+	}
+
+	if cfg.IsSet(key) {
+		return cfg.GetString(key), key, true
+	}
+
+	return "", "", false
+}
+
+// ConfigPath returns the path of config.toml in the config home.
+func ConfigPath() (string, error) {
+	homeConfig, err := GetHomeConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeConfig, "config.toml"), nil
+}
+
+// SetValue parses value for key and writes it to config.toml. Keys are
+// validated with LookupSetting.
+func SetValue(key, value string) error {
+	s, _, err := LookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := s.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+
+	// The file is read without environment overrides so they are not
+	// written to it.
+	v := viper.New()
+	v.SetConfigType("toml")
+	if data, err := os.ReadFile(path); err == nil {
+		if err := v.ReadConfig(strings.NewReader(string(data))); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	v.Set(strings.ToLower(key), parsed)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return v.WriteConfigAs(path)
+}
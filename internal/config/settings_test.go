@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	t.Setenv("XSOPS_CONFIG_HOME", t.TempDir())
+	t.Setenv("XSOPS_ENSURE_SIZE", "")
+	t.Setenv("XSOPS_ENSURE_SYMBOLS", "")
+
+	for _, kv := range [][2]string{{"ensure.size", "12"}, {"vaults.prod.ensure.size", "20"}, {"backend", "sops-exec"}, {"ensure.symbols", "!@"}} {
+		if err := SetValue(kv[0], kv[1]); err != nil {
+			t.Fatalf("SetValue(%s) error = %v", kv[0], err)
+		}
+	}
+
+	if err := SetValue("ensure.size", "big"); err == nil {
+		t.Error("SetValue() with a non numeric size should fail")
+	}
+
+	if err := SetValue("vaults.prod.vault", "x"); err == nil {
+		t.Error("SetValue() of vault for a single vault should fail")
+	}
+
+	cfg, err := GetConfig()
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+
+	// env is the value of the XSOPS_* variable of the key, unset when nil.
+	empty, five := "", "5"
+	tests := []struct {
+		vault, key    string
+		env           *string
+		value, source string
+	}{
+		{"", "ensure.size", nil, "12", "ensure.size"},
+		{"prod", "ensure.size", nil, "20", "vaults.prod.ensure.size"},
+		{"prod", "backend", nil, "sops-exec", "backend"},
+		{"prod", "ensure.size", &five, "5", "XSOPS_ENSURE_SIZE"},
+		{"", "ensure.symbols", nil, "!@", "ensure.symbols"},
+		{"", "ensure.symbols", &empty, "", "XSOPS_ENSURE_SYMBOLS"},
+		{"", "editor", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		env := EnvName(tt.key)
+		if tt.env != nil {
+			t.Setenv(env, *tt.env)
+		} else {
+			os.Unsetenv(env)
+		}
+
+		value, source, _ := Lookup(cfg, tt.vault, tt.key)
+		if value != tt.value || source != tt.source {
+			t.Errorf("Lookup(%q, %q) = %q, %q, want %q, %q", tt.vault, tt.key, value, source, tt.value, tt.source)
+		}
+	}
+}
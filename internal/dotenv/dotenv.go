@@ -0,0 +1,265 @@
+// Package dotenv reads and writes dotenv files.
+package dotenv
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Quote returns value formatted for the right hand side of a dotenv
+// assignment. Plain values are left bare, values without single quotes
+// or line breaks are single quoted and anything else is double quoted
+// with backslash escapes so multiline values round trip.
+func Quote(value string) string {
+	if value != "" && isBare(value) {
+		return value
+	}
+
+	if !strings.ContainsAny(value, "'\n\r\\") {
+		return "'" + value + "'"
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '$':
+			sb.WriteString(`\$`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func isBare(value string) bool {
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("_-./:@,+%", r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Entry is a single assignment read from a dotenv file.
+type Entry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// Parse reads the assignments in data in file order. Lines may start with
+// "export", values may be bare, single quoted (literal) or double quoted
+// (with backslash escapes) and quoted values may span multiple lines.
+// Comments start with # at the beginning of a line, at the beginning of a
+// bare value or after whitespace following a bare value.
+func Parse(data []byte) ([]Entry, error) {
+	p := &parser{src: []rune(strings.ReplaceAll(string(data), "\r\n", "\n")), line: 1}
+	entries := []Entry{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return entries, nil
+		}
+
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		line := p.line
+		key := p.readKey()
+		if key == "export" && (p.peek() == ' ' || p.peek() == '\t') {
+			p.skipSpaces()
+			key = p.readKey()
+		}
+
+		if key == "" {
+			return nil, &SyntaxError{Line: line, Msg: "expected a variable name"}
+		}
+
+		p.skipSpaces()
+		if p.eof() || p.peek() != '=' {
+			return nil, &SyntaxError{Line: line, Msg: "expected '=' after " + key}
+		}
+		p.pos++
+		p.skipSpaces()
+
+		value, err := p.readValue()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, Entry{Key: key, Value: value, Line: line})
+	}
+}
+
+// SyntaxError describes a malformed line in a dotenv file.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return "dotenv: line " + strconv.Itoa(e.Line) + ": " + e.Msg
+}
+
+type parser struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() rune {
+	return p.src[p.pos]
+}
+
+func (p *parser) next() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) skipBlank() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+		p.next()
+	}
+}
+
+func (p *parser) skipLine() {
+	for !p.eof() && p.next() != '\n' {
+	}
+}
+
+func (p *parser) readKey() string {
+	start := p.pos
+	for !p.eof() {
+		r := p.peek()
+		if r == '=' || r == ' ' || r == '\t' || r == '\n' || r == '#' {
+			break
+		}
+		p.pos++
+	}
+
+	return string(p.src[start:p.pos])
+}
+
+func (p *parser) readValue() (string, error) {
+	if p.eof() {
+		return "", nil
+	}
+
+	line := p.line
+	var value string
+	switch p.peek() {
+	case '\'', '`':
+		quote := p.next()
+		var sb strings.Builder
+		for {
+			if p.eof() {
+				return "", &SyntaxError{Line: line, Msg: "unterminated quoted value"}
+			}
+			r := p.next()
+			if r == quote {
+				break
+			}
+			sb.WriteRune(r)
+		}
+		value = sb.String()
+	case '"':
+		p.next()
+		var sb strings.Builder
+		for {
+			if p.eof() {
+				return "", &SyntaxError{Line: line, Msg: "unterminated quoted value"}
+			}
+			r := p.next()
+			if r == '"' {
+				break
+			}
+
+			if r == '\\' && !p.eof() {
+				e := p.next()
+				switch e {
+				case 'n':
+					sb.WriteRune('\n')
+				case 'r':
+					sb.WriteRune('\r')
+				case 't':
+					sb.WriteRune('\t')
+				case '\\', '"', '$', '\'', '`':
+					sb.WriteRune(e)
+				case '\n':
+					// line continuation
+				default:
+					sb.WriteRune('\\')
+					sb.WriteRune(e)
+				}
+				continue
+			}
+			sb.WriteRune(r)
+		}
+		value = sb.String()
+	default:
+		start := p.pos
+		for !p.eof() && p.peek() != '\n' {
+			// A # starts a comment at the beginning of the value, such as
+			// in A= # note, or after whitespace.
+			if p.peek() == '#' && (p.pos == start || p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+				break
+			}
+			p.pos++
+		}
+		return strings.TrimSpace(string(p.src[start:p.pos])), p.finishLine(line)
+	}
+
+	return value, p.finishLine(line)
+}
+
+// finishLine consumes trailing whitespace and an optional comment after a
+// value and fails when anything else follows on the same line.
+func (p *parser) finishLine(line int) error {
+	p.skipSpaces()
+	if p.eof() {
+		return nil
+	}
+
+	switch p.peek() {
+	case '\n':
+		p.next()
+		return nil
+	case '#':
+		p.skipLine()
+		return nil
+	default:
+		return &SyntaxError{Line: line, Msg: "unexpected characters after value"}
+	}
+}
@@ -0,0 +1,69 @@
+package dotenv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Entry
+	}{
+		{"bare", "A=1\nB=two words\n", []Entry{{"A", "1", 1}, {"B", "two words", 2}}},
+		{"empty", "A=\nB=", []Entry{{"A", "", 1}, {"B", "", 2}}},
+		{"spaces around =", "A = 1 \n", []Entry{{"A", "1", 1}}},
+		{"export", "export A=1\nexport\tB=2\n", []Entry{{"A", "1", 1}, {"B", "2", 2}}},
+		{"comments", "# first\nA=1 # note\n  # indented\nB=2#not a comment\n", []Entry{{"A", "1", 2}, {"B", "2#not a comment", 4}}},
+		{"comment as value", "A= # note\nB=#note\n", []Entry{{"A", "", 1}, {"B", "", 2}}},
+		{"single quotes", `A='a "b" \n $c' # note`, []Entry{{"A", `a "b" \n $c`, 1}}},
+		{"backticks", "A=`it's`", []Entry{{"A", "it's", 1}}},
+		{"double quotes", `A="a\n\tb \"c\" \$d \\ \x"`, []Entry{{"A", "a\n\tb \"c\" $d \\ \\x", 1}}},
+		{"multiline", "A=\"one\ntwo\"\nB='three\nfour'\nC=5", []Entry{{"A", "one\ntwo", 1}, {"B", "three\nfour", 3}, {"C", "5", 5}}},
+		{"line continuation", "A=\"one \\\ntwo\"", []Entry{{"A", "one two", 1}}},
+		{"crlf", "A=1\r\nB=\"x\r\ny\"\r\n", []Entry{{"A", "1", 1}, {"B", "x\ny", 2}}},
+		{"blank lines", "\n\n  \nA=1\n\n", []Entry{{"A", "1", 4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		line int
+	}{
+		{"=1", 1},
+		{"A", 1},
+		{"A 1", 1},
+		{"A=1\nB=\"open", 2},
+		{"A='open\n", 1},
+		{"A=\"x\" y", 1},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.data))
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) error = %v, want a SyntaxError", tt.data, err)
+			continue
+		}
+
+		if syntaxErr.Line != tt.line {
+			t.Errorf("Parse(%q) error on line %d, want %d", tt.data, syntaxErr.Line, tt.line)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits s into words, quoted strings, operators and
+// parentheses.
+func tokenize(s string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case strings.IndexByte("=!<>~&|", c) >= 0:
+			op := string(c)
+			if i+1 < len(s) && (s[i+1] == '=' || (c == '&' && s[i+1] == '&') || (c == '|' && s[i+1] == '|')) {
+				op = s[i : i+2]
+			}
+
+			switch op {
+			case "&", "|":
+				return nil, fmt.Errorf("unexpected %q at position %d, use and or or", op, i+1)
+			case "&&":
+				tokens = append(tokens, token{kind: tokenWord, text: "and", pos: i})
+			case "||":
+				tokens = append(tokens, token{kind: tokenWord, text: "or", pos: i})
+			case "!":
+				tokens = append(tokens, token{kind: tokenWord, text: "not", pos: i})
+			default:
+				tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			}
+			i += len(op)
+		default:
+			start := i
+			for i < len(s) && strings.IndexByte(" \t\n\r()\"'=!<>~&|", s[i]) < 0 {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[start:i], pos: start})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the keyword kw and consumes
+// it when it is.
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokenWord && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("not") {
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if end := p.next(); end.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ) at position %d", end.pos+1)
+		}
+		return n, nil
+	case tokenWord, tokenString:
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+
+	if p.peek().kind != tokenOp {
+		kind, name := field(t.text)
+		if t.kind == tokenString {
+			kind, name = kindTag, t.text
+		}
+		return &existsNode{kind: kind, name: name}, nil
+	}
+
+	op := p.next()
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("expected a value after %s at position %d", op.text, value.pos+1)
+	}
+
+	return newCompare(t.text, op.text, value.text, value.kind == tokenWord && p.keyword("ago"))
+}
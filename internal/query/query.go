@@ -0,0 +1,396 @@
+// Package query parses and evaluates the --where expressions that select
+// secrets by key, tags, state and dates.
+//
+// An expression compares fields with values and combines the comparisons
+// with and, or, not and parentheses:
+//
+//	env=prod and expires_at < 30d
+//	not enabled or (team ~ "plat*" and updated_at < 90d ago)
+//
+// The fields are key, enabled, disabled, expired, version, created_at,
+// updated_at and expires_at; updated_at is created_at for records that
+// were never updated. Any other name, or tags.NAME, is a tag. A
+// field on its own tests that it is set: a tag exists, a date is set or
+// a boolean is true. The operators are =, !=, <, <=, >, >= and ~, which
+// matches a glob pattern; key patterns match segment by segment like
+// vault.CompileGlob.
+//
+// Dates are compared with RFC 3339 times, dates such as 2026-01-31, now,
+// or durations relative to now such as 30d (in 30 days), 12h, 2w and
+// 90d ago or -90d (90 days ago).
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+// Query is a parsed --where expression.
+type Query struct {
+	root node
+	text string
+}
+
+// Parse parses the expression s.
+func Parse(s string) (*Query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+
+	return &Query{root: root, text: s}, nil
+}
+
+// String returns the expression the query was parsed from.
+func (q *Query) String() string {
+	return q.text
+}
+
+// Match reports whether the record stored under key matches the query
+// at now.
+func (q *Query) Match(key string, r *vault.SecretRecord, now time.Time) bool {
+	return q.root.eval(&subject{key: key, record: r, now: now})
+}
+
+// ParseDuration parses a Go duration such as 36h or a whole number of
+// days or weeks such as 14d or 2w. Negative durations are rejected.
+func ParseDuration(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		d, err := time.ParseDuration(s)
+		if err == nil && d < 0 {
+			return 0, fmt.Errorf("expected a duration that is not negative, not %s", s)
+		}
+		return d, err
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a number of days or weeks such as 14d or 2w")
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+type subject struct {
+	key    string
+	record *vault.SecretRecord
+	now    time.Time
+}
+
+type node interface {
+	eval(s *subject) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n *andNode) eval(s *subject) bool { return n.left.eval(s) && n.right.eval(s) }
+
+type orNode struct{ left, right node }
+
+func (n *orNode) eval(s *subject) bool { return n.left.eval(s) || n.right.eval(s) }
+
+type notNode struct{ node node }
+
+func (n *notNode) eval(s *subject) bool { return !n.node.eval(s) }
+
+type fieldKind int
+
+const (
+	kindTag fieldKind = iota
+	kindString
+	kindBool
+	kindInt
+	kindTime
+)
+
+var fields = map[string]fieldKind{
+	"key":        kindString,
+	"enabled":    kindBool,
+	"disabled":   kindBool,
+	"expired":    kindBool,
+	"version":    kindInt,
+	"created_at": kindTime,
+	"updated_at": kindTime,
+	"expires_at": kindTime,
+}
+
+// field returns the kind of the field name and, for tags, the tag name.
+func field(name string) (fieldKind, string) {
+	for _, prefix := range []string{"tags.", "tag."} {
+		if tag, ok := strings.CutPrefix(name, prefix); ok {
+			return kindTag, tag
+		}
+	}
+
+	if kind, ok := fields[strings.ToLower(name)]; ok {
+		return kind, strings.ToLower(name)
+	}
+
+	return kindTag, name
+}
+
+// value returns the value of a string, tag or bool field, whether it is
+// set and, for int fields, the number.
+func (s *subject) value(kind fieldKind, name string) (string, bool) {
+	switch kind {
+	case kindTag:
+		tag, ok := s.record.Tags[name]
+		if !ok {
+			return "", false
+		}
+		if tag == nil {
+			return "", true
+		}
+		return *tag, true
+	case kindString:
+		return s.key, true
+	case kindInt:
+		return strconv.Itoa(s.record.CurrentVersion()), true
+	default:
+		var b bool
+		switch name {
+		case "enabled":
+			b = s.record.Enabled
+		case "disabled":
+			b = !s.record.Enabled
+		case "expired":
+			b = s.record.IsExpired(s.now)
+		}
+		return strconv.FormatBool(b), true
+	}
+}
+
+// time returns the value of a date field, which is nil when it is not set.
+// Records that were never updated have the update time of their creation.
+func (s *subject) time(name string) *time.Time {
+	var t *time.Time
+	switch name {
+	case "created_at":
+		t = &s.record.CreatedAt
+	case "updated_at":
+		t = &s.record.UpdatedAt
+		if t.IsZero() {
+			t = &s.record.CreatedAt
+		}
+	case "expires_at":
+		t = s.record.ExpiresAt
+	}
+
+	if t == nil || t.IsZero() {
+		return nil
+	}
+
+	return t
+}
+
+// existsNode tests that a field is set.
+type existsNode struct {
+	kind fieldKind
+	name string
+}
+
+func (n *existsNode) eval(s *subject) bool {
+	switch n.kind {
+	case kindTime:
+		return s.time(n.name) != nil
+	case kindBool:
+		v, _ := s.value(n.kind, n.name)
+		return v == "true"
+	default:
+		_, ok := s.value(n.kind, n.name)
+		return ok
+	}
+}
+
+// compareNode compares a field with a value.
+type compareNode struct {
+	kind  fieldKind
+	name  string
+	op    string
+	value string
+	glob  glob.Glob
+
+	// absolute or relative to now for date fields.
+	at       *time.Time
+	relative time.Duration
+	number   int
+}
+
+func (n *compareNode) eval(s *subject) bool {
+	if n.op == "!=" {
+		eq := *n
+		eq.op = "="
+		return !eq.eval(s)
+	}
+
+	switch n.kind {
+	case kindTime:
+		t := s.time(n.name)
+		if t == nil {
+			return false
+		}
+
+		want := s.now.Add(n.relative)
+		if n.at != nil {
+			want = *n.at
+		}
+		return compare(t.Compare(want), n.op)
+	case kindInt:
+		v, _ := s.value(n.kind, n.name)
+		got, _ := strconv.Atoi(v)
+		return compare(cmpInt(got, n.number), n.op)
+	default:
+		v, ok := s.value(n.kind, n.name)
+		if !ok {
+			return false
+		}
+
+		if n.op == "~" {
+			return n.glob.Match(v)
+		}
+		return compare(strings.Compare(v, n.value), n.op)
+	}
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compare(c int, op string) bool {
+	switch op {
+	case "=", "==":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// newCompare validates the comparison of the field name with value.
+func newCompare(name, op, value string, ago bool) (node, error) {
+	kind, fieldName := field(name)
+	n := &compareNode{kind: kind, name: fieldName, op: op, value: value}
+	if op == "==" {
+		n.op = "="
+	}
+
+	if ago && kind != kindTime {
+		return nil, fmt.Errorf("%s is not a date, \"ago\" only applies to dates", name)
+	}
+
+	switch kind {
+	case kindBool:
+		if n.op != "=" && n.op != "!=" {
+			return nil, fmt.Errorf("%s is true or false and only supports = and !=", name)
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be compared with true or false, not %q", name, value)
+		}
+
+		// Compare with the canonical form.
+		n.value = strconv.FormatBool(b)
+	case kindInt:
+		if n.op == "~" {
+			return nil, fmt.Errorf("%s is a number and does not support ~", name)
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be compared with a number, not %q", name, value)
+		}
+		n.number = number
+	case kindTime:
+		if n.op == "~" {
+			return nil, fmt.Errorf("%s is a date and does not support ~", name)
+		}
+
+		if err := n.parseTime(value, ago); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	default:
+		if n.op == "~" {
+			var g glob.Glob
+			var err error
+			if kind == kindString {
+				g, err = vault.CompileGlob(value)
+			} else {
+				g, err = glob.Compile(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+			}
+			n.glob = g
+		}
+	}
+
+	return n, nil
+}
+
+// parseTime parses the date value of a comparison.
+func (n *compareNode) parseTime(value string, ago bool) error {
+	if strings.EqualFold(value, "now") && !ago {
+		return nil
+	}
+
+	if !ago {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				n.at = &t
+				return nil
+			}
+		}
+	}
+
+	// -90d is the same as 90d ago.
+	if rest, ok := strings.CutPrefix(value, "-"); ok && !ago {
+		value, ago = rest, true
+	}
+
+	d, err := ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("expected a date such as 2026-01-31 or a duration such as 30d, not %q", value)
+	}
+
+	if ago {
+		d = -d
+	}
+	n.relative = d
+	return nil
+}
@@ -0,0 +1,125 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	soon := now.Add(10 * 24 * time.Hour)
+	past := now.Add(-time.Hour)
+	prod, team := "prod", "platform"
+
+	records := map[string]*vault.SecretRecord{
+		"db-password": {
+			Enabled:   true,
+			Tags:      map[string]*string{"env": &prod, "team": &team, "pci": nil},
+			CreatedAt: now.Add(-200 * 24 * time.Hour),
+			ExpiresAt: &soon,
+			Version:   3,
+		},
+		"api-token": {
+			Enabled:   true,
+			Tags:      map[string]*string{"env": &prod},
+			CreatedAt: now.Add(-time.Hour),
+		},
+		"old-key": {
+			Enabled:   false,
+			CreatedAt: now.Add(-400 * 24 * time.Hour),
+			UpdatedAt: now.Add(-100 * 24 * time.Hour),
+			ExpiresAt: &past,
+		},
+	}
+
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"env=prod", []string{"api-token", "db-password"}},
+		{"env = prod and expires_at < 30d", []string{"db-password"}},
+		{"env != prod", []string{"old-key"}},
+		{"pci", []string{"db-password"}},
+		{"tags.team ~ 'plat*'", []string{"db-password"}},
+		{"not enabled", []string{"old-key"}},
+		{"enabled = false or expired", []string{"old-key"}},
+		{"created_at < 180d ago", []string{"db-password", "old-key"}},
+		{"created_at < -180d && !disabled", []string{"db-password"}},
+		{"not expires_at", []string{"api-token"}},
+		{"updated_at < 90d ago", []string{"db-password", "old-key"}},
+		{"updated_at > 150d ago", []string{"api-token", "old-key"}},
+		{"expires_at < 2026-10-18", []string{"old-key"}},
+		{"version >= 2", []string{"db-password"}},
+		{"key ~ '*-*' and (env=prod || team)", []string{"api-token", "db-password"}},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.where)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.where, err)
+			continue
+		}
+
+		got := []string{}
+		for _, key := range []string{"api-token", "db-password", "old-key"} {
+			if q.Match(key, records[key], now) {
+				got = append(got, key)
+			}
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%q matched %v, want %v", tt.where, got, tt.want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q matched %v, want %v", tt.where, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, where := range []string{
+		"",
+		"env =",
+		"(env=prod",
+		"env=prod)",
+		"enabled < true",
+		"enabled = maybe",
+		"expires_at < soon",
+		"version ~ 1",
+		"env = prod ago",
+		"created_at < -90d ago",
+		"env = 'prod",
+		"env & team",
+	} {
+		if _, err := Parse(where); err == nil {
+			t.Errorf("Parse(%q) should fail", where)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"14d": 14 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	}
+
+	for s, want := range tests {
+		if got, err := ParseDuration(s); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"xd", "-1d", "-2w", "-36h"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("ParseDuration(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a calendar event of an iCalendar feed.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	Duration    time.Duration
+
+	// Reminders are how long before Start alarms go off.
+	Reminders []time.Duration
+}
+
+const icsTime = "20060102T150405Z"
+
+// WriteICS writes events to w as an iCalendar (RFC 5545) feed named name.
+// now is used as the timestamp of the events.
+func WriteICS(w io.Writer, name string, events []Event, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//hyprxlabs//xsops//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if name != "" {
+		line("X-WR-CALNAME", escapeText(name))
+	}
+
+	stamp := now.UTC().Format(icsTime)
+	for _, e := range events {
+		d := e.Duration
+		if d <= 0 {
+			d = 30 * time.Minute
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", escapeText(e.UID))
+		line("DTSTAMP", stamp)
+		line("DTSTART", e.Start.UTC().Format(icsTime))
+		line("DTEND", e.Start.Add(d).UTC().Format(icsTime))
+		line("SUMMARY", escapeText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escapeText(e.Description))
+		}
+		line("TRANSP", "TRANSPARENT")
+
+		for _, r := range e.Reminders {
+			line("BEGIN", "VALARM")
+			line("ACTION", "DISPLAY")
+			line("DESCRIPTION", escapeText(e.Summary))
+			line("TRIGGER", "-"+formatICSDuration(r))
+			line("END", "VALARM")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+// escapeText escapes a TEXT value of an iCalendar property.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// writeFolded writes a content line terminated by CRLF, folding it into
+// lines of at most 75 octets without splitting UTF-8 sequences.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts
+		// towards the limit.
+		limit = 74
+	}
+	w.WriteString(s + "\r\n")
+}
+
+// formatICSDuration formats d as an RFC 5545 duration such as P7D or
+// PT12H.
+func formatICSDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	if d > 0 && d%day == 0 {
+		return fmt.Sprintf("P%dD", d/day)
+	}
+
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("PT%dH", d/time.Hour)
+	}
+
+	return fmt.Sprintf("PT%dM", d/time.Minute)
+}
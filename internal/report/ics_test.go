@@ -0,0 +1,56 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteICS(t *testing.T) {
+	start := time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	events := []Event{{
+		UID:         "abc@xsops",
+		Summary:     "api-token in prod, staging; expires",
+		Description: "Rotate it with\nxsops rotate api-token " + strings.Repeat("long ", 20),
+		Start:       start,
+		Reminders:   []time.Duration{7 * 24 * time.Hour, 12 * time.Hour},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, "xsops expirations", events, now); err != nil {
+		t.Fatalf("WriteICS() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:xsops expirations\r\n",
+		"DTSTAMP:20261017T120000Z\r\n",
+		"DTSTART:20261101T093000Z\r\n",
+		"DTEND:20261101T100000Z\r\n",
+		`SUMMARY:api-token in prod\, staging\; expires` + "\r\n",
+		"TRIGGER:-P7D\r\n",
+		"TRIGGER:-PT12H\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteICS() is missing %q:\n%s", want, out)
+		}
+	}
+
+	if !strings.Contains(out, `Rotate it with\nxsops`) {
+		t.Errorf("WriteICS() should escape line breaks:\n%s", out)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("WriteICS() line longer than 75 octets: %q", line)
+		}
+	}
+
+	if unfolded := strings.ReplaceAll(out, "\r\n ", ""); !strings.Contains(unfolded, strings.Repeat("long ", 20)) {
+		t.Errorf("WriteICS() folded lines do not unfold to the description:\n%s", out)
+	}
+}
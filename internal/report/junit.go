@@ -0,0 +1,79 @@
+// Package report writes reports about secrets in formats read by other
+// tools, such as JUnit XML for CI servers and iCalendar feeds.
+package report
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// TestSuites is the root element of a JUnit XML report.
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite groups the test cases of a JUnit XML report, such as the
+// secrets of a vault.
+type TestSuite struct {
+	Name     string     `xml:"name,attr"`
+	Tests    int        `xml:"tests,attr"`
+	Failures int        `xml:"failures,attr"`
+	Errors   int        `xml:"errors,attr"`
+	Cases    []TestCase `xml:"testcase"`
+}
+
+// TestCase is a single check of a JUnit XML report. It passed when it
+// has neither a failure nor an error.
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr,omitempty"`
+	Failure   *Problem `xml:"failure,omitempty"`
+	Error     *Problem `xml:"error,omitempty"`
+}
+
+// Problem describes why a test case failed or could not run.
+type Problem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit counts the tests, failures and errors of s and writes it to
+// w as indented JUnit XML.
+func WriteJUnit(w io.Writer, s *TestSuites) error {
+	s.Tests, s.Failures, s.Errors = 0, 0, 0
+	for i := range s.Suites {
+		suite := &s.Suites[i]
+		suite.Tests, suite.Failures, suite.Errors = len(suite.Cases), 0, 0
+		for _, c := range suite.Cases {
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			if c.Error != nil {
+				suite.Errors++
+			}
+		}
+
+		s.Tests += suite.Tests
+		s.Failures += suite.Failures
+		s.Errors += suite.Errors
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	s := &TestSuites{
+		Name: "xsops expiring",
+		Suites: []TestSuite{
+			{Name: "prod", Cases: []TestCase{
+				{Name: "db-password", ClassName: "prod"},
+				{Name: "api-token", ClassName: "prod", Failure: &Problem{Message: "expired", Type: "expired", Text: "expired <yesterday>"}},
+			}},
+			{Name: "staging", Cases: []TestCase{
+				{Name: "staging", Error: &Problem{Message: "cannot decrypt"}},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, s); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, xml.Header) {
+		t.Errorf("WriteJUnit() should start with the xml header, got %q", out[:20])
+	}
+
+	if !strings.Contains(out, `<testsuites name="xsops expiring" tests="3" failures="1" errors="1">`) {
+		t.Errorf("WriteJUnit() totals are wrong:\n%s", out)
+	}
+
+	if !strings.Contains(out, `<testsuite name="prod" tests="2" failures="1" errors="0">`) {
+		t.Errorf("WriteJUnit() suite counts are wrong:\n%s", out)
+	}
+
+	if !strings.Contains(out, "expired &lt;yesterday&gt;") {
+		t.Errorf("WriteJUnit() should escape the failure text:\n%s", out)
+	}
+
+	var parsed TestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("WriteJUnit() wrote invalid xml: %v", err)
+	}
+
+	if len(parsed.Suites) != 2 || parsed.Suites[0].Cases[1].Failure == nil {
+		t.Errorf("WriteJUnit() round trip = %+v", parsed)
+	}
+}
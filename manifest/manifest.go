@@ -0,0 +1,212 @@
+// Package manifest reads xsops.yaml, the project manifest that declares
+// the secrets an application needs, how missing ones are generated and
+// which vault holds them in each environment.
+//
+//	environments:
+//	  dev:
+//	    vault: ./dev.secrets.json
+//	  prod:
+//	    vault: prod
+//
+//	secrets:
+//	  db-password:
+//	    description: Password of the application database user
+//	    generate:
+//	      size: 32
+//	  stripe-key:
+//	    description: Issued by Stripe, set by hand
+//	  sentry-dsn:
+//	    required: false
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the manifest file.
+const FileName = "xsops.yaml"
+
+// Manifest declares the secrets of a project.
+type Manifest struct {
+	// Path is the path the manifest was loaded from.
+	Path string `yaml:"-"`
+
+	// Environments maps environment names to the vault holding their
+	// secrets.
+	Environments map[string]*Environment `yaml:"environments"`
+
+	// Secrets maps keys to the secrets the project needs.
+	Secrets map[string]*Secret `yaml:"secrets"`
+}
+
+// Environment is a deployment environment such as dev or prod.
+type Environment struct {
+	// Vault is a path relative to the manifest, a URI or the name of a
+	// registered vault.
+	Vault string `yaml:"vault"`
+}
+
+// Secret is a secret the project needs.
+type Secret struct {
+	Description string `yaml:"description,omitempty"`
+
+	// Required defaults to true. Missing optional secrets are reported
+	// but do not fail verification.
+	Required *bool `yaml:"required,omitempty"`
+
+	// Generate describes how the secret is generated when it is missing.
+	// Secrets without it have to be set by hand.
+	Generate *Generator `yaml:"generate,omitempty"`
+}
+
+// IsRequired reports whether the secret is required.
+func (s *Secret) IsRequired() bool {
+	return s.Required == nil || *s.Required
+}
+
+// Generator describes a generated secret with the same options as the
+// flags of xsops ensure.
+type Generator = vault.Generator
+
+// Load reads the manifest at path.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	m.Path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, env := range m.Environments {
+		if env == nil || env.Vault == "" {
+			return nil, fmt.Errorf("%s: environment '%s' has no vault", path, name)
+		}
+	}
+
+	for key, s := range m.Secrets {
+		if _, err := vault.SplitKey(key); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if s == nil {
+			m.Secrets[key] = &Secret{}
+		}
+	}
+
+	return m, nil
+}
+
+// Find returns the path of the manifest in dir or the closest of its
+// parents. It returns os.ErrNotExist when there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%s: %w", FileName, os.ErrNotExist)
+		}
+		dir = parent
+	}
+}
+
+// Dir returns the directory of the manifest, which relative vault paths
+// are resolved against.
+func (m *Manifest) Dir() string {
+	return filepath.Dir(m.Path)
+}
+
+// Keys returns the keys of the secrets sorted by name.
+func (m *Manifest) Keys() []string {
+	keys := make([]string, 0, len(m.Secrets))
+	for key := range m.Secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// EnvironmentNames returns the names of the environments sorted by name.
+func (m *Manifest) EnvironmentNames() []string {
+	names := make([]string, 0, len(m.Environments))
+	for name := range m.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Status is the state of a declared secret in a vault.
+type Status string
+
+// Statuses of a declared secret.
+const (
+	StatusOK       Status = "ok"
+	StatusMissing  Status = "missing"
+	StatusDisabled Status = "disabled"
+	StatusExpired  Status = "expired"
+)
+
+// Result is the state of a declared secret in a vault.
+type Result struct {
+	Key      string `json:"key" yaml:"key"`
+	Status   Status `json:"status" yaml:"status"`
+	Required bool   `json:"required" yaml:"required"`
+}
+
+// Failed reports whether the result fails verification, which is the
+// case for required secrets that are not ok.
+func (r Result) Failed() bool {
+	return r.Required && r.Status != StatusOK
+}
+
+// Verify checks every declared secret against the records of a vault at
+// the time now and returns the results sorted by key.
+func (m *Manifest) Verify(records map[string]*vault.SecretRecord, now time.Time) []Result {
+	results := make([]Result, 0, len(m.Secrets))
+	for _, key := range m.Keys() {
+		r := Result{Key: key, Status: StatusOK, Required: m.Secrets[key].IsRequired()}
+
+		record, ok := records[key]
+		switch {
+		case !ok:
+			r.Status = StatusMissing
+		case !record.Enabled:
+			r.Status = StatusDisabled
+		case record.IsExpired(now):
+			r.Status = StatusExpired
+		}
+
+		results = append(results, r)
+	}
+
+	return results
+}
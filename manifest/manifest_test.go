@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/xsops/vault"
+)
+
+const testManifest = `environments:
+  dev:
+    vault: ./dev.secrets.json
+  prod:
+    vault: prod
+secrets:
+  db-password:
+    generate:
+      size: 16
+      no_symbols: true
+  api-key: {}
+  expired: {}
+  disabled: {}
+  sentry-dsn:
+    required: false
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(testManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := Find(sub)
+	if err != nil || found != path {
+		t.Fatalf("Find() = %q, %v, want %q", found, err, path)
+	}
+
+	m, err := Load(found)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := m.EnvironmentNames(); !reflect.DeepEqual(got, []string{"dev", "prod"}) {
+		t.Errorf("EnvironmentNames() = %v", got)
+	}
+
+	g := m.Secrets["db-password"].Generate
+	if g == nil || g.Size != 16 || !g.NoSymbols {
+		t.Errorf("Secrets[db-password].Generate = %+v", g)
+	}
+
+	if !m.Secrets["api-key"].IsRequired() || m.Secrets["sentry-dsn"].IsRequired() {
+		t.Error("IsRequired() should default to true and honor required: false")
+	}
+
+	if _, err := Find(t.TempDir()); err == nil {
+		t.Error("Find() without a manifest should fail")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("environments:\n  dev: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load() of an environment without a vault should fail")
+	}
+
+	for _, key := range []string{`""`, "sops", "_xsops", "a//b", "/a", "a/", "sops/x"} {
+		if err := os.WriteFile(path, []byte("secrets:\n  "+key+": {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Load(path); !errors.Is(err, vault.ErrInvalidKey) {
+			t.Errorf("Load() of the secret key %s error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("secrets:\n  app/db/password: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load() of a namespaced key error = %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(testManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	records := map[string]*vault.SecretRecord{
+		"db-password": {Secret: "x", Enabled: true},
+		"expired":     {Secret: "x", Enabled: true, ExpiresAt: &past},
+		"disabled":    {Secret: "x"},
+	}
+
+	want := []Result{
+		{Key: "api-key", Status: StatusMissing, Required: true},
+		{Key: "db-password", Status: StatusOK, Required: true},
+		{Key: "disabled", Status: StatusDisabled, Required: true},
+		{Key: "expired", Status: StatusExpired, Required: true},
+		{Key: "sentry-dsn", Status: StatusMissing, Required: false},
+	}
+
+	got := m.Verify(records, now)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() = %+v, want %+v", got, want)
+	}
+
+	if got[4].Failed() || !got[0].Failed() || got[1].Failed() {
+		t.Error("Failed() should only report required secrets that are not ok")
+	}
+}
@@ -0,0 +1,49 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Backend encrypts and decrypts vault documents. Documents are json
+// objects that map keys to records or to namespaces, objects holding the
+// records of keys such as app/db/password. Extract and Set only address
+// the top level members of the document; the values passed to and
+// returned from them are the json encoded records or namespaces.
+//
+// Backends work on the bytes of the vault file, the path is only used to
+// select configuration such as sops creation rules.
+type Backend interface {
+	// Decrypt returns the plaintext document of the encrypted data read
+	// from path, without any sops metadata.
+	Decrypt(path string, data []byte) ([]byte, error)
+
+	// Encrypt encrypts a plaintext document for path. current holds the
+	// encrypted file that is being replaced, or nil when the file is new,
+	// so that backends can keep its recipients.
+	Encrypt(path string, plaintext []byte, current []byte) ([]byte, error)
+
+	// Extract returns the plaintext value stored under key. It returns
+	// ErrKeyNotFound when the key does not exist.
+	Extract(path string, data []byte, key string) ([]byte, error)
+
+	// Set returns the encrypted data with key set to value.
+	Set(path string, data []byte, key string, value []byte) ([]byte, error)
+}
+
+// NewBackend returns the backend registered under name:
+//   - "sops" (or an empty name) decrypts and encrypts in-process with the sops library
+//   - "sops-exec" runs the sops binary found on the PATH
+//   - "plaintext" (or "memory") stores documents unencrypted
+func NewBackend(name string) (Backend, error) {
+	switch strings.ToLower(name) {
+	case "", "sops":
+		return &SopsBackend{}, nil
+	case "sops-exec", "exec":
+		return &ExecBackend{}, nil
+	case "plaintext", "memory":
+		return &PlaintextBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend '%s', expected sops, sops-exec or plaintext", name)
+	}
+}
@@ -0,0 +1,172 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	xexec "github.com/hyprxlabs/go/exec"
+)
+
+// ExecBackend runs the sops binary for every operation.
+type ExecBackend struct {
+	// Binary is the sops executable, "sops" when empty.
+	Binary string
+
+	// Recipients are passed to sops encrypt as age recipients in place
+	// of the keys of the .sops.yaml creation rule.
+	Recipients []string
+}
+
+func (b *ExecBackend) Decrypt(path string, data []byte) ([]byte, error) {
+	return b.run(path, data, "decrypt", "--input-type", "json", "--output-type", "json", "--filename-override", path)
+}
+
+// Encrypt runs sops encrypt which always applies the creation rules of
+// the .sops.yaml file for path and generates a new data key.
+func (b *ExecBackend) Encrypt(path string, plaintext []byte, current []byte) ([]byte, error) {
+	args := []string{"encrypt", "--input-type", "json", "--output-type", "json", "--filename-override", path}
+	if len(b.Recipients) > 0 {
+		args = append(args, "--age", strings.Join(b.Recipients, ","))
+	}
+
+	return b.run(path, plaintext, args...)
+}
+
+func (b *ExecBackend) Extract(path string, data []byte, key string) ([]byte, error) {
+	treePath, ok := sopsPath(key)
+	if !ok {
+		return b.extractDecrypted(path, data, key)
+	}
+
+	out, err := b.run(path, data, "decrypt", "--input-type", "json", "--output-type", "json",
+		"--filename-override", path, "--extract", treePath)
+	if err != nil {
+		var sopsErr *SopsError
+		if errors.As(err, &sopsErr) && strings.Contains(sopsErr.Error(), "not found") {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Set writes data to a temporary file next to path and runs sops set on
+// it, passing the value on stdin so it does not show up in process lists.
+func (b *ExecBackend) Set(path string, data []byte, key string, value []byte) ([]byte, error) {
+	treePath, ok := sopsPath(key)
+	if !ok {
+		return b.setDecrypted(path, data, key, value)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".xsops-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if _, err := b.run(path, value, "set", "--value-stdin", tmp.Name(), treePath); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(tmp.Name())
+}
+
+// sopsPath returns the sops tree path of the top level key. sops splits
+// tree paths on [ without any escaping, so keys containing [ cannot be
+// addressed and false is returned.
+func sopsPath(key string) (string, bool) {
+	if strings.Contains(key, "[") {
+		return "", false
+	}
+
+	return `["` + key + `"]`, true
+}
+
+// extractDecrypted extracts key from the whole decrypted document, for
+// keys that sops cannot address.
+func (b *ExecBackend) extractDecrypted(path string, data []byte, key string) ([]byte, error) {
+	plain, err := b.Decrypt(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(plain, &doc); err != nil {
+		return nil, err
+	}
+
+	value, ok := doc[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return value, nil
+}
+
+// setDecrypted sets key in the whole decrypted document and encrypts it
+// again, for keys that sops cannot address.
+func (b *ExecBackend) setDecrypted(path string, data []byte, key string, value []byte) ([]byte, error) {
+	plain, err := b.Decrypt(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(plain, &doc); err != nil {
+		return nil, err
+	}
+
+	doc[key] = value
+	if plain, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+
+	return b.Encrypt(path, plain, data)
+}
+
+// run runs sops with args from the directory of path, passing stdin and
+// returning stdout. A non-zero exit code is reported as a *SopsError.
+func (b *ExecBackend) run(path string, stdin []byte, args ...string) ([]byte, error) {
+	binary := b.Binary
+	if binary == "" {
+		binary = "sops"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := xexec.New(binary, args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, &SopsError{Op: args[0], Err: err}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			msg := strings.TrimSpace(stderr.String())
+			return nil, &SopsError{Op: args[0], Err: fmt.Errorf("exit code %d: %s", exitErr.ExitCode(), msg)}
+		}
+		return nil, &SopsError{Op: args[0], Err: err}
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"encoding/json"
+)
+
+// PlaintextBackend stores documents as unencrypted json. It needs no keys
+// or binaries, which makes it useful for tests and throwaway vaults; it
+// must not be used for real secrets.
+type PlaintextBackend struct{}
+
+func (b *PlaintextBackend) Decrypt(path string, data []byte) ([]byte, error) {
+	doc, err := b.parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
+
+func (b *PlaintextBackend) Encrypt(path string, plaintext []byte, current []byte) ([]byte, error) {
+	doc, err := b.parse(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.emit(doc)
+}
+
+func (b *PlaintextBackend) Extract(path string, data []byte, key string) ([]byte, error) {
+	doc, err := b.parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := doc[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return value, nil
+}
+
+func (b *PlaintextBackend) Set(path string, data []byte, key string, value []byte) ([]byte, error) {
+	doc, err := b.parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc[key] = value
+	return b.emit(doc)
+}
+
+func (b *PlaintextBackend) parse(data []byte) (map[string]json.RawMessage, error) {
+	doc := map[string]json.RawMessage{}
+	if len(data) == 0 {
+		return doc, nil
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	delete(doc, "sops")
+	return doc, nil
+}
+
+func (b *PlaintextBackend) emit(doc map[string]json.RawMessage) ([]byte, error) {
+	out, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,229 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	sopsconfig "github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	sopsjson "github.com/getsops/sops/v3/stores/json"
+	"github.com/getsops/sops/v3/version"
+)
+
+// SopsBackend decrypts and encrypts vaults in-process with the sops
+// library. Keys are resolved the same way as the sops binary, for example
+// age identities from SOPS_AGE_KEY_FILE or the user config directory.
+type SopsBackend struct {
+	// Recipients are the age recipients of new vaults. When empty the
+	// keys of the .sops.yaml creation rule for the vault are used.
+	Recipients []string
+}
+
+// defaultEncryptedRegex limits encryption to the secret of each record,
+// like the .sops.yaml written by xsops init. It is used for vaults with
+// recipients but no .sops.yaml.
+const defaultEncryptedRegex = "^(secret)$"
+
+func (b *SopsBackend) Decrypt(path string, data []byte) ([]byte, error) {
+	tree, _, err := b.decrypt(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(path).EmitPlainFile(tree.Branches)
+}
+
+// Encrypt keeps the data key and recipients of current. New files use the
+// creation rules of the .sops.yaml file that applies to path.
+func (b *SopsBackend) Encrypt(path string, plaintext []byte, current []byte) ([]byte, error) {
+	store := newStore(path)
+	branches, err := store.LoadPlainFile(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		return encryptNew(path, branches, b.Recipients)
+	}
+
+	tree, err := store.LoadEncryptedFile(current)
+	if err != nil {
+		return nil, err
+	}
+	tree.FilePath = path
+
+	dataKey, err := tree.Metadata.GetDataKeyWithKeyServices(keyServices(), nil)
+	if err != nil {
+		return nil, &SopsError{Op: "encrypt", Err: err}
+	}
+
+	tree.Branches = branches
+	return encryptTree(store, &tree, dataKey)
+}
+
+func (b *SopsBackend) Extract(path string, data []byte, key string) ([]byte, error) {
+	tree, _, err := b.decrypt(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := tree.Branches[0].Truncate([]interface{}{key})
+	if err != nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return newStore(path).EmitValue(value)
+}
+
+func (b *SopsBackend) Set(path string, data []byte, key string, value []byte) ([]byte, error) {
+	store := newStore(path)
+	tree, dataKey, err := b.decrypt(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := json.Marshal(map[string]json.RawMessage{key: value})
+	if err != nil {
+		return nil, err
+	}
+
+	branches, err := store.LoadPlainFile(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	tree.Branches[0], _ = tree.Branches[0].Set([]interface{}{key}, branches[0][0].Value)
+	return encryptTree(store, tree, dataKey)
+}
+
+// decrypt loads and decrypts data, verifying its MAC, and returns the
+// tree along with its data key.
+func (b *SopsBackend) decrypt(path string, data []byte) (*sops.Tree, []byte, error) {
+	tree, err := newStore(path).LoadEncryptedFile(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree.FilePath = path
+
+	dataKey, err := common.DecryptTree(common.DecryptTreeOpts{
+		Tree:        &tree,
+		KeyServices: keyServices(),
+		Cipher:      aes.NewCipher(),
+	})
+	if err != nil {
+		return nil, nil, &SopsError{Op: "decrypt", Err: err}
+	}
+
+	if len(tree.Branches) == 0 {
+		tree.Branches = sops.TreeBranches{sops.TreeBranch{}}
+	}
+
+	return &tree, dataKey, nil
+}
+
+// newStore returns the sops json store configured by the stores section
+// of the .sops.yaml file that applies to path, like the sops binary.
+func newStore(path string) *sopsjson.Store {
+	conf := sopsconfig.NewStoresConfig()
+	if confPath, err := sopsconfig.FindConfigFile(path); err == nil {
+		if c, err := sopsconfig.LoadStoresConfig(confPath); err == nil {
+			conf = c
+		}
+	}
+
+	return sopsjson.NewStore(&conf.JSON)
+}
+
+func keyServices() []keyservice.KeyServiceClient {
+	return []keyservice.KeyServiceClient{keyservice.NewLocalClient()}
+}
+
+func encryptTree(store *sopsjson.Store, tree *sops.Tree, dataKey []byte) ([]byte, error) {
+	err := common.EncryptTree(common.EncryptTreeOpts{
+		Tree:    tree,
+		Cipher:  aes.NewCipher(),
+		DataKey: dataKey,
+	})
+	if err != nil {
+		return nil, &SopsError{Op: "encrypt", Err: err}
+	}
+
+	return store.EmitEncryptedFile(*tree)
+}
+
+// encryptNew encrypts branches for path with a new data key using the
+// creation rules of the .sops.yaml file that applies to path. When
+// recipients are given they replace the keys of the creation rule.
+func encryptNew(path string, branches sops.TreeBranches, recipients []string) ([]byte, error) {
+	conf, err := creationRule(path)
+	if err != nil {
+		if len(recipients) == 0 {
+			return nil, err
+		}
+		conf = &sopsconfig.Config{EncryptedRegex: defaultEncryptedRegex}
+	}
+
+	if len(recipients) > 0 {
+		keys, err := sopsage.MasterKeysFromRecipients(strings.Join(recipients, ","))
+		if err != nil {
+			return nil, &SopsError{Op: "encrypt", Err: err}
+		}
+
+		group := sops.KeyGroup{}
+		for _, key := range keys {
+			group = append(group, key)
+		}
+		conf.KeyGroups = []sops.KeyGroup{group}
+		conf.ShamirThreshold = 0
+	}
+
+	tree := sops.Tree{
+		Branches: branches,
+		FilePath: path,
+		Metadata: sops.Metadata{
+			KeyGroups:               conf.KeyGroups,
+			ShamirThreshold:         conf.ShamirThreshold,
+			UnencryptedSuffix:       conf.UnencryptedSuffix,
+			EncryptedSuffix:         conf.EncryptedSuffix,
+			UnencryptedRegex:        conf.UnencryptedRegex,
+			EncryptedRegex:          conf.EncryptedRegex,
+			UnencryptedCommentRegex: conf.UnencryptedCommentRegex,
+			EncryptedCommentRegex:   conf.EncryptedCommentRegex,
+			MACOnlyEncrypted:        conf.MACOnlyEncrypted,
+			Version:                 version.Version,
+		},
+	}
+
+	dataKey, errs := tree.GenerateDataKeyWithKeyServices(keyServices())
+	if len(errs) > 0 {
+		return nil, &SopsError{Op: "encrypt", Err: fmt.Errorf("could not generate data key: %w", errors.Join(errs...))}
+	}
+
+	return encryptTree(newStore(path), &tree, dataKey)
+}
+
+// creationRule finds the .sops.yaml file for path, searching from the
+// directory of path upwards, and returns the matching creation rule.
+func creationRule(path string) (*sopsconfig.Config, error) {
+	confPath, err := sopsconfig.FindConfigFile(path)
+	if err != nil {
+		return nil, &SopsError{Op: "encrypt", Err: fmt.Errorf("no .sops.yaml found for %s: %w", path, err)}
+	}
+
+	conf, err := sopsconfig.LoadCreationRuleForFile(confPath, path, nil)
+	if err != nil {
+		return nil, &SopsError{Op: "encrypt", Err: err}
+	}
+
+	if conf == nil {
+		return nil, &SopsError{Op: "encrypt", Err: fmt.Errorf("%s has no creation rules", confPath)}
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// newSopsTestDir creates a directory with a .sops.yaml for a new age
+// identity and makes the identity available to sops.
+func newSopsTestDir(t *testing.T) string {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SOPS_AGE_KEY", identity.String())
+
+	dir := t.TempDir()
+	conf := "creation_rules:\n  - encrypted_regex: '^(secret)$'\n    age: " + identity.Recipient().String() + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ".sops.yaml"), []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestSopsBackendRoundTrip(t *testing.T) {
+	dir := newSopsTestDir(t)
+
+	v, err := Create(filepath.Join(dir, "xsops.secrets.json"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if _, err := v.Set("db-password", "hunter2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("the secret is stored in plain text")
+	}
+
+	if !strings.Contains(string(data), `"enabled": true`) {
+		t.Error("record metadata should not be encrypted with the encrypted_regex rule")
+	}
+
+	record, err := v.Get("db-password")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if record.Secret != "hunter2" {
+		t.Errorf("Get().Secret = %q, want %q", record.Secret, "hunter2")
+	}
+
+	if _, err := v.Set("other", "value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := v.Remove("db-password"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	keys, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(keys) != 1 || keys[0] != "other" {
+		t.Errorf("List() = %v, want [other]", keys)
+	}
+}
+
+func TestSopsBackendWrongKey(t *testing.T) {
+	dir := newSopsTestDir(t)
+
+	v, err := Create(filepath.Join(dir, "xsops.secrets.json"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY", other.String())
+
+	_, err = v.Get("missing")
+	var sopsErr *SopsError
+	if err == nil || !errors.As(err, &sopsErr) {
+		t.Errorf("Get() with the wrong key error = %v, want *SopsError", err)
+	}
+}
+
+func TestSopsBackendRecipients(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY", identity.String())
+
+	backend := &SopsBackend{Recipients: []string{identity.Recipient().String()}}
+	v, err := Create(filepath.Join(t.TempDir(), "xsops.secrets.json"), WithBackend(backend))
+	if err != nil {
+		t.Fatalf("Create() without .sops.yaml error = %v", err)
+	}
+
+	if _, err := v.Set("db-password", "hunter2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), identity.Recipient().String()) {
+		t.Error("the vault is not encrypted for the recipient")
+	}
+
+	if !strings.Contains(string(data), `"enabled": true`) {
+		t.Error("record metadata should not be encrypted by default")
+	}
+
+	record, err := v.Get("db-password")
+	if err != nil || record.Secret != "hunter2" {
+		t.Errorf("Get() = %v, %v, want hunter2", record, err)
+	}
+}
@@ -0,0 +1,207 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBackups is the number of backups kept for each vault.
+const DefaultBackups = 10
+
+// backupTimeFormat names backup files so they sort by time.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Backup is an encrypted copy of a vault taken before it was changed.
+type Backup struct {
+	// ID identifies the backup, it is derived from the time it was taken.
+	ID string `json:"id"`
+
+	// Path is the path of the backup file.
+	Path string `json:"path"`
+
+	// Time is when the backup was taken.
+	Time time.Time `json:"time"`
+
+	// Size is the size of the encrypted backup in bytes.
+	Size int64 `json:"size"`
+}
+
+// WithBackups keeps the last keep encrypted generations of the vault in
+// a directory below dir before every change. A keep of zero or less
+// disables backups.
+func WithBackups(dir string, keep int) Option {
+	return func(v *Vault) {
+		v.backupDir = dir
+		v.backupKeep = keep
+	}
+}
+
+// backupPath returns the directory holding the backups of the vault.
+// Each vault gets its own directory named after the file and a hash of
+// its path so vaults with the same name do not share backups.
+func (v *Vault) backupPath() string {
+	sum := sha256.Sum256([]byte(v.path))
+	name := strings.TrimSuffix(filepath.Base(v.path), filepath.Ext(v.path))
+	return filepath.Join(v.backupDir, name+"-"+hex.EncodeToString(sum[:6]))
+}
+
+// backup stores data, the current encrypted contents of the vault, as a
+// new backup and removes the backups beyond the number to keep.
+func (v *Vault) backup(data []byte) error {
+	if v.backupDir == "" || v.backupKeep <= 0 {
+		return nil
+	}
+
+	dir := v.backupPath()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	name := time.Now().UTC().Format(backupTimeFormat) + filepath.Ext(v.path)
+	if err := writeFile(filepath.Join(dir, name), data, 0600); err != nil {
+		return err
+	}
+
+	backups, err := v.Backups()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backups[min(len(backups), v.backupKeep):] {
+		if err := os.Remove(b.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Backups returns the backups of the vault, newest first.
+func (v *Vault) Backups() ([]Backup, error) {
+	if v.backupDir == "" {
+		return []Backup{}, nil
+	}
+
+	entries, err := os.ReadDir(v.backupPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, &Error{Op: "backups", Path: v.path, Err: err}
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		t, err := time.Parse(backupTimeFormat, id)
+		if entry.IsDir() || err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, &Error{Op: "backups", Path: v.path, Err: err}
+		}
+
+		backups = append(backups, Backup{
+			ID:   id,
+			Path: filepath.Join(v.backupPath(), entry.Name()),
+			Time: t,
+			Size: info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+
+	return backups, nil
+}
+
+// Restore replaces the vault with the backup id. The current contents
+// are backed up first so a restore can itself be undone.
+func (v *Vault) Restore(id string) (*Backup, error) {
+	b, err := v.findBackup(id)
+	if err != nil {
+		return nil, &Error{Op: "restore", Path: v.path, Err: err}
+	}
+
+	err = v.modify(func(data []byte) ([]byte, error) {
+		return os.ReadFile(b.Path)
+	})
+	if err != nil {
+		return nil, &Error{Op: "restore", Path: v.path, Err: err}
+	}
+
+	return b, nil
+}
+
+// Undo reverts the last change by restoring the newest backup and
+// removing it, so calling Undo again reverts the change before that.
+func (v *Vault) Undo() (*Backup, error) {
+	backups, err := v.Backups()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(backups) == 0 {
+		return nil, &Error{Op: "undo", Path: v.path, Err: ErrNoBackup}
+	}
+
+	b := backups[0]
+	if err := v.undo(b); err != nil {
+		return nil, &Error{Op: "undo", Path: v.path, Err: err}
+	}
+
+	return &b, nil
+}
+
+// undo replaces the vault with the backup b without backing up the
+// current contents and removes b.
+func (v *Vault) undo(b Backup) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+
+	unlock, err := acquireLock(v.path, v.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := v.commit(data, out, false); err != nil {
+		return err
+	}
+
+	return os.Remove(b.Path)
+}
+
+func (v *Vault) findBackup(id string) (*Backup, error) {
+	backups, err := v.Backups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range backups {
+		if b.ID == id {
+			return &b, nil
+		}
+	}
+
+	return nil, ErrNoBackup
+}
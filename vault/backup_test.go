@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newBackupTestVault(t *testing.T, keep int) *Vault {
+	t.Helper()
+
+	dir := t.TempDir()
+	v, err := Create(filepath.Join(dir, "xsops.secrets.json"),
+		WithBackend(&PlaintextBackend{}), WithBackups(filepath.Join(dir, "backups"), keep))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	return v
+}
+
+func TestBackupsKeep(t *testing.T) {
+	v := newBackupTestVault(t, 3)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := v.Set(key, "1"); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	backups, err := v.Backups()
+	if err != nil {
+		t.Fatalf("Backups() error = %v", err)
+	}
+
+	if len(backups) != 3 {
+		t.Fatalf("Backups() returned %d backups, want 3", len(backups))
+	}
+
+	if backups[0].ID <= backups[1].ID {
+		t.Errorf("Backups() is not sorted newest first: %s, %s", backups[0].ID, backups[1].ID)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(v.Path()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".tmp" {
+			t.Errorf("temporary file %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestUndo(t *testing.T) {
+	v := newBackupTestVault(t, DefaultBackups)
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if _, err := v.Set("b", "2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if _, err := v.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	keys, _ := v.List()
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("List() after Undo() = %v, want [a]", keys)
+	}
+
+	if _, err := v.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	keys, _ = v.List()
+	if len(keys) != 0 {
+		t.Errorf("List() after second Undo() = %v, want []", keys)
+	}
+
+	if _, err := v.Undo(); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("Undo() error = %v, want %v", err, ErrNoBackup)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	v := newBackupTestVault(t, DefaultBackups)
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := v.Remove("a"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	backups, _ := v.Backups()
+	if _, err := v.Restore(backups[0].ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if _, err := v.Get("a"); err != nil {
+		t.Errorf("Get() after Restore() error = %v", err)
+	}
+
+	after, _ := v.Backups()
+	if len(after) != len(backups)+1 {
+		t.Errorf("Restore() did not back up the current vault")
+	}
+
+	if _, err := v.Restore("bogus"); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("Restore() error = %v, want %v", err, ErrNoBackup)
+	}
+}
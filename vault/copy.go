@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Copy copies the records stored in v under the keys of renames to the
+// keys they map to in dst, which may be v itself. The records are copied
+// whole, with their tags, dates and history, and are encrypted for the
+// recipients of dst. Keys that are already set in dst fail with
+// ErrKeyExists unless overwrite is true.
+func (v *Vault) Copy(dst *Vault, renames map[string]string, overwrite bool) error {
+	return v.transfer(dst, renames, overwrite, false)
+}
+
+// Move moves the records stored in v under the keys of renames to the
+// keys they map to in dst like Copy, then removes them from v. Between
+// two vaults the records are written to dst before they are removed from
+// v, so a failure never loses a secret. When a record changes in v while
+// it is copied, Move fails with ErrConflict and leaves it in v.
+func (v *Vault) Move(dst *Vault, renames map[string]string, overwrite bool) error {
+	return v.transfer(dst, renames, overwrite, true)
+}
+
+func (v *Vault) transfer(dst *Vault, renames map[string]string, overwrite, move bool) error {
+	op := "copy"
+	if move {
+		op = "move"
+	}
+
+	sources := make([]string, 0, len(renames))
+	targets := make(map[string]string, len(renames))
+	keys := make([]string, 0, len(renames))
+	for from, to := range renames {
+		if err := checkKey(to); err != nil {
+			return &Error{Op: op, Path: dst.path, Key: to, Err: err}
+		}
+
+		if other, ok := targets[to]; ok {
+			return &Error{Op: op, Path: dst.path, Key: to,
+				Err: fmt.Errorf("%w: both %q and %q would be stored under it", ErrInvalidKey, other, from)}
+		}
+		targets[to] = from
+		sources = append(sources, from)
+		keys = append(keys, to)
+	}
+	sort.Strings(sources)
+
+	// put stores the records under their new keys in records, which were
+	// read from dst.
+	put := func(records, from map[string]*SecretRecord) error {
+		for _, key := range sources {
+			to := renames[key]
+			if _, ok := records[to]; ok && !overwrite {
+				return fmt.Errorf("%s: %w", to, ErrKeyExists)
+			}
+
+			record := *from[key]
+			records[to] = &record
+		}
+
+		return nil
+	}
+
+	if dst.path == v.path {
+		return v.Update(func(records map[string]*SecretRecord) error {
+			from := make(map[string]*SecretRecord, len(sources))
+			for _, key := range sources {
+				record, ok := records[key]
+				if !ok {
+					return fmt.Errorf("%s: %w", key, ErrKeyNotFound)
+				}
+				from[key] = record
+			}
+
+			if move {
+				for _, key := range sources {
+					delete(records, key)
+				}
+			}
+
+			return put(records, from)
+		}, WithChange(op), withReplaced(keys...))
+	}
+
+	from, err := v.All()
+	if err != nil {
+		return &Error{Op: op, Path: v.path, Err: err}
+	}
+
+	for _, key := range sources {
+		if _, ok := from[key]; !ok {
+			return &Error{Op: op, Path: v.path, Key: key, Err: ErrKeyNotFound}
+		}
+	}
+
+	err = dst.Update(func(records map[string]*SecretRecord) error {
+		return put(records, from)
+	}, WithChange(op), withReplaced(keys...))
+	if err != nil {
+		return err
+	}
+
+	if !move {
+		return nil
+	}
+
+	// The source was read without its lock, so only remove records that
+	// are still the ones copied to dst.
+	err = v.Update(func(records map[string]*SecretRecord) error {
+		for _, key := range sources {
+			if record, ok := records[key]; ok && !equalRecords(record, from[key]) {
+				return fmt.Errorf("%s: %w", key, ErrConflict)
+			}
+		}
+
+		for _, key := range sources {
+			delete(records, key)
+		}
+		return nil
+	}, WithChange(op))
+	if err != nil {
+		return &Error{Op: op, Path: v.path, Err: fmt.Errorf("copied to %s but not removed: %w", dst.path, err)}
+	}
+
+	return nil
+}
+
+// equalRecords reports whether a and b encode to the same record.
+func equalRecords(a, b *SecretRecord) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ja, jb)
+}
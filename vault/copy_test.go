@@ -0,0 +1,176 @@
+package vault
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveWithinVault(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("old/token", "one", WithTags(map[string]string{"env": "prod"})); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, err := v.Set("taken", "two"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := v.Move(v, map[string]string{"old/token": "taken"}, false); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("Move() onto a set key error = %v, want ErrKeyExists", err)
+	}
+
+	if err := v.Move(v, map[string]string{"old/token": "new/token"}, false); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+
+	if _, err := v.Get("old/token"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(old/token) error = %v, want ErrKeyNotFound after the move", err)
+	}
+
+	record, err := v.Get("new/token")
+	if err != nil {
+		t.Fatalf("Get(new/token) error = %v", err)
+	}
+
+	if _, ok := record.Tags["env"]; record.Secret != "one" || !ok || record.Version != 1 {
+		t.Errorf("Get(new/token) = %+v, want the moved record unchanged", record)
+	}
+
+	if err := v.Copy(v, map[string]string{"new/token": "taken"}, true); err != nil {
+		t.Fatalf("Copy() with overwrite error = %v", err)
+	}
+
+	if record, _ := v.Get("taken"); record == nil || record.Secret != "one" {
+		t.Errorf("Get(taken) = %+v, want the copied secret", record)
+	}
+
+	if err := v.Copy(v, map[string]string{"missing": "other"}, false); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Copy() of a missing key error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestMoveBetweenVaults(t *testing.T) {
+	src := newTestVault(t)
+	dst, err := Create(filepath.Join(t.TempDir(), "other.secrets.json"), WithBackend(&PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := src.Set(key, "value-"+key); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	if err := src.Copy(dst, map[string]string{"a": "a"}, false); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	if err := src.Move(dst, map[string]string{"a": "a", "b": "app/b"}, false); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("Move() onto a copied key error = %v, want ErrKeyExists", err)
+	}
+
+	if err := src.Move(dst, map[string]string{"a": "a", "b": "app/b"}, true); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+
+	if keys, _ := src.List(); len(keys) != 0 {
+		t.Errorf("source keys = %v, want none after the move", keys)
+	}
+
+	if record, err := dst.Get("app/b"); err != nil || record.Secret != "value-b" {
+		t.Errorf("Get(app/b) = %+v, %v, want the moved secret", record, err)
+	}
+}
+
+func TestMoveOverwriteKeepsHistory(t *testing.T) {
+	other, err := Create(filepath.Join(t.TempDir(), "other.secrets.json"), WithBackend(&PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for name, dst := range map[string]*Vault{"within": nil, "between": other} {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVault(t)
+			if dst == nil {
+				dst = v
+			}
+
+			for _, secret := range []string{"a1", "a2", "a3"} {
+				if _, err := v.Set("a", secret); err != nil {
+					t.Fatalf("Set() error = %v", err)
+				}
+			}
+			if _, err := dst.Set("b", "b1"); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			want, err := v.Get("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := v.Move(dst, map[string]string{"a": "b"}, true); err != nil {
+				t.Fatalf("Move() with overwrite error = %v", err)
+			}
+
+			got, err := dst.Get("b")
+			if err != nil {
+				t.Fatalf("Get(b) error = %v", err)
+			}
+
+			if !equalRecords(got, want) {
+				t.Errorf("Get(b) = %+v, want the moved record %+v", got, want)
+			}
+			if got.Version != 3 || len(got.History) != 2 || got.History[0].Secret != "a1" || got.History[1].Secret != "a2" {
+				t.Errorf("Get(b) version %d, history %+v, want version 3 with a1 and a2", got.Version, got.History)
+			}
+		})
+	}
+}
+
+// hookBackend calls hook before encrypting.
+type hookBackend struct {
+	PlaintextBackend
+	hook func()
+}
+
+func (b *hookBackend) Encrypt(path string, plaintext []byte, current []byte) ([]byte, error) {
+	if b.hook != nil {
+		b.hook()
+		b.hook = nil
+	}
+	return b.PlaintextBackend.Encrypt(path, plaintext, current)
+}
+
+func TestMoveConflict(t *testing.T) {
+	src := newTestVault(t)
+	if _, err := src.Set("token", "old"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	backend := &hookBackend{}
+	path := filepath.Join(t.TempDir(), "other.secrets.json")
+	if _, err := Create(path, WithBackend(&PlaintextBackend{})); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	dst, err := Open(path, WithBackend(backend))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	// Rotate the source while the record is written to dst.
+	backend.hook = func() {
+		if _, err := src.Set("token", "new"); err != nil {
+			t.Errorf("Set() error = %v", err)
+		}
+	}
+
+	if err := src.Move(dst, map[string]string{"token": "token"}, false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Move() error = %v, want ErrConflict", err)
+	}
+
+	if record, err := src.Get("token"); err != nil || record.Secret != "new" {
+		t.Errorf("source token = %+v, %v, want the new secret kept", record, err)
+	}
+}
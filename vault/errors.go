@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrVaultNotFound is returned when the vault file does not exist.
+	ErrVaultNotFound = errors.New("vault not found")
+
+	// ErrKeyNotFound is returned when a key does not exist in the vault.
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrInvalidKey is returned for empty or reserved keys.
+	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrLocked is returned when the lock of the vault is held by another
+	// process for longer than the lock timeout.
+	ErrLocked = errors.New("vault is locked")
+
+	// ErrConflict is returned when the vault file was changed by another
+	// writer after it was read, so writing it would lose that change.
+	ErrConflict = errors.New("vault was modified by another process since it was read")
+
+	// ErrNoBackup is returned when a vault has no backup to restore.
+	ErrNoBackup = errors.New("no backup found")
+
+	// ErrReadOnly is returned when changing a vault opened read-only.
+	ErrReadOnly = errors.New("vault is read-only")
+
+	// ErrKeyExists is returned when copying or moving a secret to a key
+	// that is already set, unless overwriting is allowed.
+	ErrKeyExists = errors.New("key already exists")
+
+	// ErrVersionNotFound is returned when a record has no such version in
+	// its history.
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// Error records a failed vault operation along with the vault path
+// and the key that caused it.
+type Error struct {
+	Op   string
+	Path string
+	Key  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("%s %s in %s: %v", e.Op, e.Key, e.Path, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SopsError reports a failure of sops to decrypt or encrypt a vault,
+// such as a missing key to decrypt the data key or a MAC mismatch.
+type SopsError struct {
+	Op  string
+	Err error
+}
+
+func (e *SopsError) Error() string {
+	return fmt.Sprintf("sops %s: %v", e.Op, e.Err)
+}
+
+func (e *SopsError) Unwrap() error {
+	return e.Err
+}
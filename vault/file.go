@@ -0,0 +1,56 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writeFile atomically replaces the file at path with data. The data is
+// written to a temporary file in the same directory, synced to disk and
+// renamed over path, so a crash or a full disk never leaves a partially
+// written vault behind. The mode of an existing file is kept.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, ".xsops-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash. This is
+	// not supported on every platform, so errors are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
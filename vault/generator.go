@@ -0,0 +1,60 @@
+package vault
+
+import (
+	"github.com/hyprxlabs/go/secrets"
+)
+
+// Generator describes how a secret is generated. It is stored with the
+// records it generated so they can be rotated with the same settings.
+// The zero value generates DefaultSize characters from upper and lower
+// case letters, digits and DefaultSymbols.
+type Generator struct {
+	Size      int16  `json:"size,omitempty" yaml:"size,omitempty"`
+	Chars     string `json:"chars,omitempty" yaml:"chars,omitempty"`
+	Symbols   string `json:"symbols,omitempty" yaml:"symbols,omitempty"`
+	NoUpper   bool   `json:"no_upper,omitempty" yaml:"no_upper,omitempty"`
+	NoLower   bool   `json:"no_lower,omitempty" yaml:"no_lower,omitempty"`
+	NoDigits  bool   `json:"no_digits,omitempty" yaml:"no_digits,omitempty"`
+	NoSymbols bool   `json:"no_symbols,omitempty" yaml:"no_symbols,omitempty"`
+}
+
+// Options returns the options passed to secrets.Generate. Chars takes
+// precedence over the other character settings.
+func (g *Generator) Options() []secrets.SetOption {
+	if g.Chars != "" {
+		return []secrets.SetOption{secrets.WithChars(g.Chars)}
+	}
+
+	opts := []secrets.SetOption{}
+	if !g.NoUpper {
+		opts = append(opts, secrets.WithUpper(true))
+	}
+	if !g.NoLower {
+		opts = append(opts, secrets.WithLower(true))
+	}
+	if !g.NoDigits {
+		opts = append(opts, secrets.WithDigits(true))
+	}
+
+	switch {
+	case g.NoSymbols:
+		opts = append(opts, secrets.WithNoSymbols())
+	case g.Symbols != "":
+		opts = append(opts, secrets.WithSymbols(g.Symbols))
+	default:
+		opts = append(opts, secrets.WithSymbols(DefaultSymbols))
+	}
+
+	return opts
+}
+
+// Generate returns a new secret. A size of zero or less uses
+// DefaultSize.
+func (g *Generator) Generate() (string, error) {
+	size := g.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+
+	return secrets.Generate(size, g.Options()...)
+}
@@ -0,0 +1,173 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// DefaultHistory is the number of prior versions kept in each record.
+const DefaultHistory = 10
+
+// Version is a secret of a record along with when and by whom it was
+// set. CreatedAt is when the secret was set and UpdatedAt when it was
+// replaced by the next version; it is zero for the current secret.
+type Version struct {
+	Version   int       `json:"version"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	Change    string    `json:"change,omitempty"`
+}
+
+// WithHistory keeps the last keep versions of each secret in its record
+// when the secret changes. A keep of zero or less disables the history.
+// The default is DefaultHistory.
+func WithHistory(keep int) Option {
+	return func(v *Vault) {
+		v.historyKeep = keep
+	}
+}
+
+// WithActor sets who is recorded as having changed secrets. The default
+// is user@host of the current process.
+func WithActor(actor string) Option {
+	return func(v *Vault) {
+		v.actor = actor
+	}
+}
+
+// CurrentVersion returns the version number of the current secret.
+// Records written before versions were tracked are at version 1.
+func (r *SecretRecord) CurrentVersion() int {
+	return max(r.Version, 1)
+}
+
+// Versions returns every version of the record, newest first, starting
+// with the current secret.
+func (r *SecretRecord) Versions() []Version {
+	created := r.UpdatedAt
+	if created.IsZero() {
+		created = r.CreatedAt
+	}
+
+	versions := []Version{{
+		Version:   r.CurrentVersion(),
+		Secret:    r.Secret,
+		CreatedAt: created,
+		ChangedBy: r.ChangedBy,
+		Change:    r.Change,
+	}}
+	for i := len(r.History) - 1; i >= 0; i-- {
+		versions = append(versions, r.History[i])
+	}
+
+	return versions
+}
+
+// GetVersion returns version n of the record, which is either the
+// current secret or one kept in its history.
+func (r *SecretRecord) GetVersion(n int) (*Version, error) {
+	for _, version := range r.Versions() {
+		if version.Version == n {
+			return &version, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, n)
+}
+
+// Rollback makes version n of the record's history the current secret
+// and returns the updated record. The replaced secret is kept in the
+// history like any other change.
+func (v *Vault) Rollback(key string, n int) (*SecretRecord, error) {
+	if err := checkKey(key); err != nil {
+		return nil, &Error{Op: "rollback", Path: v.path, Key: key, Err: err}
+	}
+
+	var record *SecretRecord
+	err := v.modify(func(data []byte) ([]byte, error) {
+		var err error
+		record, err = v.extract(data, key)
+		if err != nil {
+			return nil, err
+		}
+
+		version, err := record.GetVersion(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if version.Version == record.CurrentVersion() {
+			return nil, nil
+		}
+
+		before := *record
+		now := time.Now().UTC()
+		record.Secret = version.Secret
+		record.UpdatedAt = now
+		v.track(record, &before, fmt.Sprintf("rollback to version %d", n), now)
+
+		return v.put(data, key, record)
+	})
+	if err != nil {
+		return nil, &Error{Op: "rollback", Path: v.path, Key: key, Err: err}
+	}
+
+	return record, nil
+}
+
+// track records a change to record, whose state before the change was
+// before, or nil for new records. When the secret changed the old one
+// is kept in the history, which is trimmed to the retention of the
+// vault, and the version is incremented.
+func (v *Vault) track(record, before *SecretRecord, change string, now time.Time) {
+	if before == nil {
+		if record.Version == 0 {
+			record.Version = 1
+			record.ChangedBy = v.changedBy()
+			record.Change = change
+		}
+		return
+	}
+
+	if record.Secret == before.Secret {
+		return
+	}
+
+	history := before.History
+	if v.historyKeep > 0 {
+		old := before.Versions()[0]
+		old.UpdatedAt = now
+		history = append(history[:len(history):len(history)], old)
+		history = history[max(len(history)-v.historyKeep, 0):]
+	} else {
+		history = nil
+	}
+
+	record.History = history
+	record.Version = before.CurrentVersion() + 1
+	record.ChangedBy = v.changedBy()
+	record.Change = change
+}
+
+// changedBy returns the actor recorded for changes, defaulting to
+// user@host.
+func (v *Vault) changedBy() string {
+	if v.actor != "" {
+		return v.actor
+	}
+
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return name + "@" + host
+	}
+
+	return name
+}
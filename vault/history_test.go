@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	created := newTestVault(t)
+	v, err := Open(created.Path(), WithBackend(&PlaintextBackend{}), WithHistory(2), WithActor("tester"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	for _, value := range []string{"one", "two", "three", "four"} {
+		if _, err := v.Set("token", value); err != nil {
+			t.Fatalf("Set(%q) error = %v", value, err)
+		}
+	}
+
+	record, err := v.Get("token")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if record.Version != 4 || record.ChangedBy != "tester" || record.Change != "set" {
+		t.Errorf("Get() = version %d by %q (%q), want version 4 set by tester", record.Version, record.ChangedBy, record.Change)
+	}
+
+	if len(record.History) != 2 || record.History[0].Secret != "two" || record.History[1].Secret != "three" {
+		t.Fatalf("History = %+v, want versions 2 and 3", record.History)
+	}
+
+	if record.History[1].Version != 3 || record.History[1].UpdatedAt.IsZero() {
+		t.Errorf("History[1] = %+v, want version 3 with the time it was replaced", record.History[1])
+	}
+
+	if _, err := record.GetVersion(1); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("GetVersion(1) error = %v, want ErrVersionNotFound once trimmed", err)
+	}
+
+	if _, err := v.Set("token", ""); err != nil {
+		t.Fatalf("Set() without a value error = %v", err)
+	}
+
+	rolled, err := v.Rollback("token", 2)
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	if rolled.Secret != "two" || rolled.Version != 5 || rolled.Change != "rollback to version 2" {
+		t.Errorf("Rollback() = %q version %d (%q), want two as version 5", rolled.Secret, rolled.Version, rolled.Change)
+	}
+
+	if last := rolled.History[len(rolled.History)-1]; last.Secret != "four" || last.Version != 4 {
+		t.Errorf("Rollback() kept %+v, want version 4 in the history", last)
+	}
+
+	if _, err := v.Rollback("token", 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Rollback() of a missing version error = %v, want ErrVersionNotFound", err)
+	}
+
+	err = v.Update(func(records map[string]*SecretRecord) error {
+		records["token"].Secret = "imported"
+		records["other"] = &SecretRecord{Secret: "new", Enabled: true}
+		return nil
+	}, WithChange("import"))
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	all, err := v.All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+
+	if all["token"].Version != 6 || all["token"].Change != "import" || all["other"].Version != 1 {
+		t.Errorf("Update() = token version %d (%q), other version %d", all["token"].Version, all["token"].Change, all["other"].Version)
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	created := newTestVault(t)
+	v, err := Open(created.Path(), WithBackend(&PlaintextBackend{}), WithHistory(0))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	v.Set("token", "one")
+	record, err := v.Set("token", "two")
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if len(record.History) != 0 || record.Version != 2 {
+		t.Errorf("Set() = %+v, want version 2 without history", record)
+	}
+
+	if versions := record.Versions(); len(versions) != 1 || versions[0].Secret != "two" {
+		t.Errorf("Versions() = %+v, want only the current secret", versions)
+	}
+}
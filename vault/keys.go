@@ -0,0 +1,197 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Separator separates the namespaces of a key. The key app/db/password
+// is stored as the record password in the object db of the object app.
+const Separator = "/"
+
+// SplitKey returns the segments of key. Every segment must be non-empty
+// and the first segment cannot be sops or _xsops, which hold the sops and
+// xsops metadata.
+func SplitKey(key string) ([]string, error) {
+	segments := strings.Split(key, Separator)
+	for _, s := range segments {
+		if s == "" {
+			return nil, fmt.Errorf("%w: %q has an empty namespace", ErrInvalidKey, key)
+		}
+	}
+
+	if segments[0] == "sops" || segments[0] == metadataKey {
+		return nil, fmt.Errorf("%w: %q is reserved for sops", ErrInvalidKey, key)
+	}
+
+	return segments, nil
+}
+
+// CompileGlob compiles a pattern that matches keys segment by segment:
+// * and ? do not match the separator while ** matches any number of
+// segments, so app/* matches app/token but not app/db/password.
+func CompileGlob(pattern string) (glob.Glob, error) {
+	return glob.Compile(pattern, '/')
+}
+
+func checkKey(key string) error {
+	_, err := SplitKey(key)
+	return err
+}
+
+// isRecord reports whether raw is a record rather than a namespace.
+// Records are objects with a string secret; namespaces are objects whose
+// members are records or namespaces, so a namespace may contain a key
+// named secret.
+func isRecord(raw json.RawMessage) bool {
+	var probe struct {
+		Secret json.RawMessage `json:"secret"`
+	}
+
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(bytes.TrimSpace(probe.Secret), []byte(`"`))
+}
+
+// flatten returns the records of the nested document doc keyed by their
+// full keys. Keys stored flat at the top level, as written before keys
+// had namespaces, are returned as they are.
+func flatten(doc map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	records := map[string]json.RawMessage{}
+	var walk func(prefix string, doc map[string]json.RawMessage) error
+	walk = func(prefix string, doc map[string]json.RawMessage) error {
+		for name, raw := range doc {
+			key := prefix + name
+			if isRecord(raw) {
+				if _, ok := records[key]; ok {
+					return fmt.Errorf("%w: %q is stored twice", ErrInvalidKey, key)
+				}
+				records[key] = raw
+				continue
+			}
+
+			child := map[string]json.RawMessage{}
+			if err := json.Unmarshal(raw, &child); err != nil {
+				return fmt.Errorf("%s: expected a record or a namespace: %w", key, err)
+			}
+
+			if err := walk(key+Separator, child); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	top := make(map[string]json.RawMessage, len(doc))
+	for name, raw := range doc {
+		if name != metadataKey {
+			top[name] = raw
+		}
+	}
+
+	if err := walk("", top); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// nest builds the nested document that stores records under their keys.
+// It fails when a key is both a record and the namespace of other keys.
+func nest(records map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	type namespace map[string]any
+	root := namespace{}
+	for _, key := range keys {
+		segments, err := SplitKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		ns := root
+		for i, s := range segments[:len(segments)-1] {
+			switch child := ns[s].(type) {
+			case nil:
+				next := namespace{}
+				ns[s] = next
+				ns = next
+			case namespace:
+				ns = child
+			default:
+				return nil, fmt.Errorf("%w: %q is a secret and cannot be the namespace of %q",
+					ErrInvalidKey, strings.Join(segments[:i+1], Separator), key)
+			}
+		}
+
+		last := segments[len(segments)-1]
+		if _, ok := ns[last]; ok {
+			return nil, fmt.Errorf("%w: %q is a namespace and cannot be a secret", ErrInvalidKey, key)
+		}
+		ns[last] = records[key]
+	}
+
+	var encode func(ns namespace) (map[string]json.RawMessage, error)
+	encode = func(ns namespace) (map[string]json.RawMessage, error) {
+		doc := make(map[string]json.RawMessage, len(ns))
+		for name, value := range ns {
+			switch value := value.(type) {
+			case json.RawMessage:
+				doc[name] = value
+			case namespace:
+				child, err := encode(value)
+				if err != nil {
+					return nil, err
+				}
+
+				raw, err := json.Marshal(child)
+				if err != nil {
+					return nil, err
+				}
+				doc[name] = raw
+			}
+		}
+
+		return doc, nil
+	}
+
+	return encode(root)
+}
+
+// lookup returns the record stored under the segments below raw, the
+// value of the first segment.
+func lookup(raw json.RawMessage, segments []string) (json.RawMessage, error) {
+	for _, s := range segments {
+		if isRecord(raw) {
+			return nil, ErrKeyNotFound
+		}
+
+		ns := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &ns); err != nil {
+			return nil, ErrKeyNotFound
+		}
+
+		var ok bool
+		if raw, ok = ns[s]; !ok {
+			return nil, ErrKeyNotFound
+		}
+	}
+
+	if !isRecord(raw) {
+		return nil, ErrKeyNotFound
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,161 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	v := newTestVault(t)
+
+	keys := []string{"app/db/password", "app/db/user", "app/token", `we"ird/[key]`, "top"}
+	for _, key := range keys {
+		if _, err := v.Set(key, "value of "+key); err != nil {
+			t.Fatalf("Set(%q) error = %v", key, err)
+		}
+	}
+
+	for _, key := range keys {
+		record, err := v.Get(key)
+		if err != nil || record.Secret != "value of "+key {
+			t.Errorf("Get(%q) = %v, %v", key, record, err)
+		}
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("vault is not nested: %v", err)
+	}
+
+	if _, ok := doc["app"]["db"]; !ok {
+		t.Errorf("app/db/password should be stored in nested objects, got %s", data)
+	}
+
+	listed, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	want := []string{"app/db/password", "app/db/user", "app/token", "top", `we"ird/[key]`}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("List() = %v, want %v", listed, want)
+	}
+
+	for _, key := range []string{"app", "app/db", "app/db/password/x", "missing/key"} {
+		if _, err := v.Get(key); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrKeyNotFound", key, err)
+		}
+	}
+
+	if _, err := v.Set("app/db", "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Set() of a namespace error = %v, want ErrInvalidKey", err)
+	}
+
+	if _, err := v.Set("top/child", "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Set() below a secret error = %v, want ErrInvalidKey", err)
+	}
+
+	for _, key := range []string{"", "app//x", "/app", "app/", "sops/x"} {
+		if _, err := v.Set(key, "x"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Set(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if err := v.Remove("app/db/password"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	if err := v.Remove("app/db/user"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	data, _ = os.ReadFile(v.Path())
+	if strings.Contains(string(data), `"db"`) {
+		t.Errorf("Remove() should drop empty namespaces, got %s", data)
+	}
+}
+
+func TestLegacyFlatKeys(t *testing.T) {
+	v := newTestVault(t)
+
+	legacy := `{"app/token": {"secret": "old", "enabled": true}, "plain": {"secret": "p", "enabled": true}}`
+	if err := os.WriteFile(v.Path(), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := v.Get("app/token")
+	if err != nil || record.Secret != "old" {
+		t.Fatalf("Get() of a flat key = %v, %v", record, err)
+	}
+
+	if _, err := v.Set("app/other", "new"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(v.Path())
+	var doc map[string]json.RawMessage
+	json.Unmarshal(data, &doc)
+	if _, ok := doc["app/token"]; ok {
+		t.Errorf("Set() should move flat keys into namespaces, got %s", data)
+	}
+
+	all, err := v.All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+
+	if len(all) != 3 || all["app/token"].Secret != "old" || all["app/other"].Secret != "new" {
+		t.Errorf("All() = %v", all)
+	}
+}
+
+func TestNamespaceNamedSecret(t *testing.T) {
+	v := newTestVault(t)
+
+	if _, err := v.Set("app/secret", "s"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	record, err := v.Get("app/secret")
+	if err != nil || record.Secret != "s" {
+		t.Errorf("Get() = %v, %v", record, err)
+	}
+
+	if _, err := v.Get("app"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(app) error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"app/*", "app/token", true},
+		{"app/*", "app/db/password", false},
+		{"app/**", "app/db/password", true},
+		{"*/db/*", "app/db/password", true},
+		{"*token", "app/token", false},
+		{"**token", "app/token", true},
+	}
+
+	for _, tt := range tests {
+		g, err := CompileGlob(tt.pattern)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q) error = %v", tt.pattern, err)
+		}
+
+		if got := g.Match(tt.key); got != tt.want {
+			t.Errorf("CompileGlob(%q).Match(%q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLockTimeout is how long a write waits for the lock of a vault
+// held by another process.
+const DefaultLockTimeout = 10 * time.Second
+
+// StaleLockAge is the age after which a lock file is considered left
+// behind by a crashed process and removed.
+const StaleLockAge = 2 * time.Minute
+
+// WithLockTimeout sets how long writes wait for the lock of the vault.
+// The default is DefaultLockTimeout.
+func WithLockTimeout(d time.Duration) Option {
+	return func(v *Vault) {
+		v.lockTimeout = d
+	}
+}
+
+// lockPath returns the path of the advisory lock file for the vault at
+// path.
+func lockPath(path string) string {
+	return path + ".lock"
+}
+
+// acquireLock creates the lock file of the vault at path, waiting up to
+// timeout for another process to release it. The returned function
+// removes the lock file.
+func acquireLock(path string, timeout time.Duration) (func(), error) {
+	name := lockPath(path)
+	deadline := time.Now().Add(timeout)
+	wait := 5 * time.Millisecond
+
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n%s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		info, err := os.Stat(name)
+		if err == nil && time.Since(info.ModTime()) > StaleLockAge {
+			os.Remove(name)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s is held by %s", ErrLocked, name, lockHolder(name))
+		}
+
+		// Jitter keeps waiting processes from polling in lock step.
+		time.Sleep(wait + time.Duration(rand.Int64N(int64(wait))))
+		if wait < 50*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// lockHolder describes the process that owns the lock file name.
+func lockHolder(name string) string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "another process"
+	}
+
+	pid, _, _ := strings.Cut(string(data), "\n")
+	if _, err := strconv.Atoi(pid); err != nil {
+		return "another process"
+	}
+
+	return "process " + pid
+}
+
+// revision identifies the contents of an encrypted vault file. For sops
+// files it is built from the lastmodified time and the MAC of the sops
+// metadata, which change on every write; otherwise it is a hash of data.
+func revision(data []byte) string {
+	var doc struct {
+		Sops *struct {
+			LastModified string `json:"lastmodified"`
+			MAC          string `json:"mac"`
+		} `json:"sops"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err == nil && doc.Sops != nil && doc.Sops.MAC != "" {
+		return doc.Sops.LastModified + "|" + doc.Sops.MAC
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSet(t *testing.T) {
+	v := newTestVault(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			other, err := Open(v.Path(), WithBackend(&PlaintextBackend{}))
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if _, err := other.Set(fmt.Sprintf("key-%02d", i), "value"); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Set() error = %v", err)
+	}
+
+	keys, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(keys) != 20 {
+		t.Errorf("List() returned %d keys, want 20", len(keys))
+	}
+
+	if _, err := os.Stat(lockPath(v.Path())); !os.IsNotExist(err) {
+		t.Errorf("lock file was not removed: %v", err)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	v := newTestVault(t)
+	v.lockTimeout = 50 * time.Millisecond
+
+	unlock, err := acquireLock(v.Path(), time.Second)
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+	defer unlock()
+
+	if _, err := v.Set("a", "1"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Set() error = %v, want %v", err, ErrLocked)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	v := newTestVault(t)
+
+	name := lockPath(v.Path())
+	if err := os.WriteFile(name, []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * StaleLockAge)
+	if err := os.Chtimes(name, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+}
+
+func TestUpdateConflict(t *testing.T) {
+	v := newTestVault(t)
+
+	err := v.Update(func(records map[string]*SecretRecord) error {
+		// Simulates a writer that does not take the lock, such as sops.
+		return os.WriteFile(v.Path(), []byte("{\"b\": {\"secret\": \"2\"}}\n"), 0644)
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Update() error = %v, want %v", err, ErrConflict)
+	}
+
+	if _, err := v.Get("b"); err != nil {
+		t.Errorf("Update() overwrote the other change: %v", err)
+	}
+}
+
+func TestReplaceConflict(t *testing.T) {
+	v := newTestVault(t)
+
+	plain, rev, err := v.Decrypt()
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := v.Replace(plain, rev); !errors.Is(err, ErrConflict) {
+		t.Errorf("Replace() error = %v, want %v", err, ErrConflict)
+	}
+
+	if _, err := v.Get("a"); err != nil {
+		t.Errorf("Replace() overwrote the other change: %v", err)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	a := []byte(`{"a": 1, "sops": {"lastmodified": "2024-01-01T00:00:00Z", "mac": "ENC[x]"}}`)
+	b := []byte(`{"a": 2, "sops": {"lastmodified": "2024-01-01T00:00:00Z", "mac": "ENC[x]"}}`)
+	c := []byte(`{"a": 1, "sops": {"lastmodified": "2024-01-02T00:00:00Z", "mac": "ENC[y]"}}`)
+
+	if revision(a) != revision(b) {
+		t.Error("revision() differs for the same sops metadata")
+	}
+
+	if revision(a) == revision(c) {
+		t.Error("revision() is equal for different sops metadata")
+	}
+
+	if revision([]byte(`{"a": 1}`)) == revision([]byte(`{"a": 2}`)) {
+		t.Error("revision() is equal for different plaintext files")
+	}
+}
@@ -0,0 +1,205 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	sopsconfig "github.com/getsops/sops/v3/config"
+	sopsjson "github.com/getsops/sops/v3/stores/json"
+)
+
+// Merge merges ours and theirs, two versions of a vault changed from
+// base, key by key. A key changed or removed on one side only takes that
+// change and a key changed the same way on both sides is kept. When both
+// sides changed a key, changes to different fields, such as the tags on
+// one side and the expiration on the other, are merged. Keys with the
+// same field changed differently on both sides, or changed on one side
+// and removed on the other, are conflicts: they keep the record of ours
+// and are returned sorted.
+//
+// Only the secret, enabled, expires_at, tags and generator fields are
+// compared. The version and history of the secret follow the side its
+// secret is taken from.
+func Merge(base, ours, theirs map[string]*SecretRecord) (merged map[string]*SecretRecord, conflicts []string) {
+	keys := map[string]bool{}
+	for _, records := range []map[string]*SecretRecord{base, ours, theirs} {
+		for key := range records {
+			keys[key] = true
+		}
+	}
+
+	merged = map[string]*SecretRecord{}
+	for key := range keys {
+		record, ok := mergeRecord(base[key], ours[key], theirs[key])
+		if !ok {
+			conflicts = append(conflicts, key)
+			record = ours[key]
+		}
+
+		if record != nil {
+			merged[key] = record
+		}
+	}
+
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
+
+// mergeRecord merges the records a and b, changed from o, field by field.
+// Any of them may be nil. It returns false when they conflict.
+func mergeRecord(o, a, b *SecretRecord) (*SecretRecord, bool) {
+	switch {
+	case sameRecord(a, b), sameRecord(o, b):
+		return a, true
+	case sameRecord(o, a):
+		return b, true
+	case o == nil || a == nil || b == nil:
+		return a, false
+	}
+
+	merged := *a
+	for _, field := range recordFields {
+		switch {
+		case field.equal(o, b), field.equal(a, b):
+		case field.equal(o, a):
+			field.take(&merged, b)
+		default:
+			return a, false
+		}
+	}
+
+	if b.UpdatedAt.After(merged.UpdatedAt) {
+		merged.UpdatedAt = b.UpdatedAt
+	}
+
+	return &merged, true
+}
+
+// sameRecord reports whether a and b, which may be nil, hold the same
+// record, ignoring dates, versions and history.
+func sameRecord(a, b *SecretRecord) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	for _, field := range recordFields {
+		if !field.equal(a, b) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordField is a field of a record that is merged on its own.
+type recordField struct {
+	equal func(a, b *SecretRecord) bool
+	take  func(dst, src *SecretRecord)
+}
+
+var recordFields = []recordField{
+	{
+		equal: func(a, b *SecretRecord) bool { return a.Secret == b.Secret },
+		take: func(dst, src *SecretRecord) {
+			dst.Secret = src.Secret
+			dst.Previous = src.Previous
+			dst.Version = src.Version
+			dst.ChangedBy = src.ChangedBy
+			dst.Change = src.Change
+			dst.History = src.History
+		},
+	},
+	{
+		equal: func(a, b *SecretRecord) bool { return a.Enabled == b.Enabled },
+		take:  func(dst, src *SecretRecord) { dst.Enabled = src.Enabled },
+	},
+	{
+		equal: func(a, b *SecretRecord) bool {
+			if a.ExpiresAt == nil || b.ExpiresAt == nil {
+				return a.ExpiresAt == b.ExpiresAt
+			}
+			return a.ExpiresAt.Equal(*b.ExpiresAt)
+		},
+		take: func(dst, src *SecretRecord) { dst.ExpiresAt = src.ExpiresAt },
+	},
+	{
+		equal: func(a, b *SecretRecord) bool {
+			return len(a.Tags) == len(b.Tags) && (len(a.Tags) == 0 || reflect.DeepEqual(a.Tags, b.Tags))
+		},
+		take: func(dst, src *SecretRecord) { dst.Tags = src.Tags },
+	},
+	{
+		equal: func(a, b *SecretRecord) bool { return reflect.DeepEqual(a.Generator, b.Generator) },
+		take:  func(dst, src *SecretRecord) { dst.Generator = src.Generator },
+	},
+}
+
+// Recipients returns the keys that the vault file contents data is
+// encrypted for, such as age recipients and PGP fingerprints, sorted.
+// Vaults that are not encrypted with sops have none.
+func Recipients(data []byte) ([]string, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if _, ok := doc["sops"]; !ok {
+		return nil, nil
+	}
+
+	tree, err := sopsjson.NewStore(&sopsconfig.JSONStoreConfig{}).LoadEncryptedFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := []string{}
+	for i, group := range tree.Metadata.KeyGroups {
+		for _, key := range group {
+			recipient := key.TypeToIdentifier() + ":" + key.ToString()
+			if len(tree.Metadata.KeyGroups) > 1 {
+				recipient = fmt.Sprintf("%d/%s", i, recipient)
+			}
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	sort.Strings(recipients)
+	return recipients, nil
+}
+
+// Encode encrypts records as the contents of a vault file stored at path.
+// The data key, recipients and fingerprint key of current, the encrypted
+// file being replaced, are kept; when current is nil the vault is
+// encrypted like a new vault.
+func Encode(path string, records map[string]*SecretRecord, current []byte, opts ...Option) ([]byte, error) {
+	flat := make(map[string]json.RawMessage, len(records))
+	for key, record := range records {
+		raw, err := json.Marshal(record)
+		if err != nil {
+			return nil, &Error{Op: "encode", Path: path, Key: key, Err: err}
+		}
+		flat[key] = raw
+	}
+
+	doc, err := nest(flat)
+	if err != nil {
+		return nil, &Error{Op: "encode", Path: path, Err: err}
+	}
+
+	v := newVault(path, opts)
+	var meta map[string]json.RawMessage
+	if current != nil {
+		if meta, err = v.parse(current); err != nil {
+			return nil, &Error{Op: "encode", Path: path, Err: err}
+		}
+	}
+
+	out, err := v.encode(current, meta, doc)
+	if err != nil {
+		return nil, &Error{Op: "encode", Path: path, Err: err}
+	}
+
+	return out, nil
+}
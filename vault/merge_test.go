@@ -0,0 +1,201 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func TestMerge(t *testing.T) {
+	record := func(secret string) *SecretRecord {
+		return &SecretRecord{Secret: secret, Enabled: true, Version: 1}
+	}
+
+	base := map[string]*SecretRecord{
+		"same":     record("1"),
+		"ours":     record("1"),
+		"theirs":   record("1"),
+		"both":     record("1"),
+		"conflict": record("1"),
+		"removed":  record("1"),
+	}
+	ours := map[string]*SecretRecord{
+		"same":     record("1"),
+		"ours":     record("2"),
+		"theirs":   record("1"),
+		"both":     record("2"),
+		"conflict": record("2"),
+		"removed":  record("1"),
+		"added":    record("a"),
+	}
+	theirs := map[string]*SecretRecord{
+		"same":     record("1"),
+		"ours":     record("1"),
+		"theirs":   record("3"),
+		"both":     record("2"),
+		"conflict": record("3"),
+		"new/key":  record("b"),
+	}
+
+	merged, conflicts := Merge(base, ours, theirs)
+	if !reflect.DeepEqual(conflicts, []string{"conflict"}) {
+		t.Errorf("Merge() conflicts = %v, want [conflict]", conflicts)
+	}
+
+	want := map[string]string{
+		"same": "1", "ours": "2", "theirs": "3", "both": "2",
+		"conflict": "2", "added": "a", "new/key": "b",
+	}
+	if len(merged) != len(want) {
+		t.Errorf("Merge() = %d records, want %d", len(merged), len(want))
+	}
+	for key, secret := range want {
+		if merged[key] == nil || merged[key].Secret != secret {
+			t.Errorf("Merge()[%q] = %+v, want %q", key, merged[key], secret)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	records := map[string]*SecretRecord{
+		"app/token": {Secret: "1", Enabled: true},
+	}
+
+	data, err := Encode("xsops.secrets.json", records, nil, WithBackend(&PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	decoded, err := Decode("xsops.secrets.json", data, WithBackend(&PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if decoded.Records["app/token"] == nil || decoded.Records["app/token"].Secret != "1" {
+		t.Errorf("Decode(Encode()) = %v, want app/token", decoded.Records)
+	}
+
+	records["app"] = &SecretRecord{Secret: "2"}
+	if _, err := Encode("xsops.secrets.json", records, nil, WithBackend(&PlaintextBackend{})); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Encode() of a key that is also a namespace error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	expires := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	prod, dev := "prod", "dev"
+
+	base := &SecretRecord{Secret: "1", Enabled: true, Version: 1, UpdatedAt: updated}
+
+	// Tags changed on ours, the expiration and the secret on theirs.
+	ours := *base
+	ours.Tags = map[string]*string{"env": &prod}
+	ours.UpdatedAt = updated.Add(time.Hour)
+
+	theirs := *base
+	theirs.ExpiresAt = &expires
+	theirs.Secret = "2"
+	theirs.Version = 2
+	theirs.History = []Version{{Version: 1, Secret: "1"}}
+	theirs.UpdatedAt = updated.Add(2 * time.Hour)
+
+	merged, conflicts := Merge(
+		map[string]*SecretRecord{"a": base},
+		map[string]*SecretRecord{"a": &ours},
+		map[string]*SecretRecord{"a": &theirs},
+	)
+	if len(conflicts) != 0 {
+		t.Fatalf("Merge() conflicts = %v, want none", conflicts)
+	}
+
+	want := &SecretRecord{
+		Secret:    "2",
+		Enabled:   true,
+		ExpiresAt: &expires,
+		Tags:      map[string]*string{"env": &prod},
+		UpdatedAt: updated.Add(2 * time.Hour),
+		Version:   2,
+		History:   []Version{{Version: 1, Secret: "1"}},
+	}
+	if !reflect.DeepEqual(merged["a"], want) {
+		t.Errorf("Merge() = %+v, want %+v", merged["a"], want)
+	}
+	if ours.ExpiresAt != nil || theirs.Tags != nil {
+		t.Error("Merge() modified its inputs")
+	}
+
+	// The same field changed differently on both sides.
+	theirs = *base
+	theirs.Tags = map[string]*string{"env": &dev}
+	_, conflicts = Merge(
+		map[string]*SecretRecord{"a": base},
+		map[string]*SecretRecord{"a": &ours},
+		map[string]*SecretRecord{"a": &theirs},
+	)
+	if !reflect.DeepEqual(conflicts, []string{"a"}) {
+		t.Errorf("Merge() of different tags conflicts = %v, want [a]", conflicts)
+	}
+
+	// Only the dates, version or history differ: the records are the same.
+	theirs = *base
+	theirs.Version = 5
+	theirs.UpdatedAt = updated.Add(time.Hour)
+	theirs.CreatedAt = updated
+	merged, conflicts = Merge(
+		map[string]*SecretRecord{"a": base},
+		map[string]*SecretRecord{"a": &ours},
+		map[string]*SecretRecord{"a": &theirs},
+	)
+	if len(conflicts) != 0 || merged["a"] != &ours {
+		t.Errorf("Merge() = %+v, %v, want ours", merged["a"], conflicts)
+	}
+
+	// Changed on one side and removed on the other.
+	_, conflicts = Merge(
+		map[string]*SecretRecord{"a": base},
+		map[string]*SecretRecord{"a": &ours},
+		map[string]*SecretRecord{},
+	)
+	if !reflect.DeepEqual(conflicts, []string{"a"}) {
+		t.Errorf("Merge() of a removed key conflicts = %v, want [a]", conflicts)
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	recipients, err := Recipients([]byte(`{"a": {"secret": "1"}}`))
+	if err != nil || recipients != nil {
+		t.Errorf("Recipients() of a plaintext vault = %v, %v, want none", recipients, err)
+	}
+
+	dir := newSopsTestDir(t)
+	v, err := Create(filepath.Join(dir, "xsops.secrets.json"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := age.ParseX25519Identity(os.Getenv("SOPS_AGE_KEY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, err = Recipients(data)
+	if err != nil {
+		t.Fatalf("Recipients() error = %v", err)
+	}
+
+	want := []string{"age:" + identity.Recipient().String()}
+	if !reflect.DeepEqual(recipients, want) {
+		t.Errorf("Recipients() = %v, want %v", recipients, want)
+	}
+}
@@ -0,0 +1,110 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// metadataKey is the top level member of a vault document that holds the
+// xsops metadata of the vault. It is never returned as a record.
+const metadataKey = "_xsops"
+
+// metadata is the xsops metadata of a vault.
+type metadata struct {
+	// FingerprintKey keys the fingerprints of the secrets. It is stored
+	// as secret so that the encrypted_regex '^(secret)$' written by
+	// xsops init, which leaves everything else in plaintext, encrypts it
+	// along with the secrets of the records.
+	FingerprintKey []byte `json:"secret,omitempty"`
+}
+
+// hasFingerprintKey reports whether the document doc holds metadata with
+// a fingerprint key. Vaults written by earlier versions stored the key as
+// fingerprint_key, which the encrypted_regex left in plaintext; that key
+// is ignored and replaced on the next write.
+func hasFingerprintKey(doc map[string]json.RawMessage) bool {
+	raw, ok := doc[metadataKey]
+	if !ok {
+		return false
+	}
+
+	meta := &metadata{}
+	return json.Unmarshal(raw, meta) == nil && len(meta.FingerprintKey) > 0
+}
+
+// newMetadata returns the encoded metadata of a new vault.
+func newMetadata() (json.RawMessage, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&metadata{FingerprintKey: key})
+}
+
+// Snapshot is the decrypted contents of a vault at one point in time.
+type Snapshot struct {
+	// Records holds the records keyed by their full keys.
+	Records map[string]*SecretRecord
+
+	fingerprintKey []byte
+}
+
+// newSnapshot decodes the records and metadata of the document doc.
+func newSnapshot(doc map[string]json.RawMessage) (*Snapshot, error) {
+	records, err := parseRecords(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{Records: records}
+	if raw, ok := doc[metadataKey]; ok {
+		meta := &metadata{}
+		if err := json.Unmarshal(raw, meta); err != nil {
+			return nil, err
+		}
+		s.fingerprintKey = meta.FingerprintKey
+	}
+
+	return s, nil
+}
+
+// CanFingerprint reports whether the vault has a fingerprint key. Vaults
+// created by older versions of xsops get one on their next change.
+func (s *Snapshot) CanFingerprint() bool {
+	return len(s.fingerprintKey) > 0
+}
+
+// Fingerprint returns a short HMAC of secret keyed by the vault, which
+// tells values apart without revealing them. Checking a guessed value
+// against it requires the fingerprint key, which is only readable by
+// those who can decrypt the vault. It is empty when the vault has no
+// fingerprint key.
+func (s *Snapshot) Fingerprint(secret string) string {
+	if !s.CanFingerprint() {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, s.fingerprintKey)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// Snapshot decrypts the vault once and returns its records along with
+// the key of their fingerprints.
+func (v *Vault) Snapshot() (*Snapshot, error) {
+	_, doc, err := v.decrypt()
+	if err != nil {
+		return nil, &Error{Op: "list", Path: v.path, Err: err}
+	}
+
+	s, err := newSnapshot(doc)
+	if err != nil {
+		return nil, &Error{Op: "list", Path: v.path, Err: err}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,164 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("token", "secret"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	before, err := v.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	if !before.CanFingerprint() || len(before.Fingerprint("secret")) != 12 {
+		t.Fatalf("Fingerprint() = %q, want 12 characters", before.Fingerprint("secret"))
+	}
+
+	if keys, _ := v.List(); len(keys) != 1 {
+		t.Errorf("List() = %v, want the metadata hidden", keys)
+	}
+
+	// Every kind of write keeps the fingerprint key.
+	if err := v.Update(func(records map[string]*SecretRecord) error {
+		records["other"] = &SecretRecord{Secret: "x"}
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := v.Remove("other"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	plain, rev, err := v.Decrypt()
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if err := v.Replace(plain, rev); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	after, err := v.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	if after.Fingerprint("secret") != before.Fingerprint("secret") {
+		t.Error("the fingerprint changed after writing the vault")
+	}
+
+	other := newTestVault(t)
+	otherSnapshot, err := other.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	if otherSnapshot.Fingerprint("secret") == before.Fingerprint("secret") {
+		t.Error("two vaults have the same fingerprint for a secret")
+	}
+
+	if _, err := v.Set(metadataKey+"/secret", "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Set() of the metadata error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestFingerprintLegacyVault(t *testing.T) {
+	v := newTestVault(t)
+	if err := os.WriteFile(v.Path(), []byte(`{"token": {"secret": "x", "enabled": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := v.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	if s.CanFingerprint() || s.Fingerprint("x") != "" {
+		t.Error("a vault without metadata has a fingerprint key")
+	}
+
+	if _, err := v.Set("token", "y"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if s, err = v.Snapshot(); err != nil || !s.CanFingerprint() {
+		t.Errorf("Snapshot() after Set() = %v, %v, want a fingerprint key", s, err)
+	}
+}
+
+func TestFingerprintKeyEncrypted(t *testing.T) {
+	dir := newSopsTestDir(t)
+	v, err := Create(filepath.Join(dir, "xsops.secrets.json"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if _, err := v.Set("token", "hunter2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	s, err := v.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if !s.CanFingerprint() {
+		t.Fatal("the vault has no fingerprint key")
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, _ := json.Marshal(s.fingerprintKey)
+	for _, leak := range [][]byte{s.fingerprintKey, bytes.Trim(encoded, `"`), []byte(hex.EncodeToString(s.fingerprintKey))} {
+		if bytes.Contains(data, leak) {
+			t.Fatalf("the fingerprint key is stored in plaintext:\n%s", data)
+		}
+	}
+
+	if !bytes.Contains(data, []byte(`"_xsops"`)) {
+		t.Errorf("the vault has no metadata:\n%s", data)
+	}
+}
+
+func TestFingerprintKeyLegacyField(t *testing.T) {
+	v := newTestVault(t)
+	legacy := `{"_xsops": {"fingerprint_key": "c2VjcmV0LWtleS1mb3ItdGVzdHM="}, "token": {"secret": "x", "enabled": true}}`
+	if err := os.WriteFile(v.Path(), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := v.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if s.CanFingerprint() {
+		t.Error("the plaintext fingerprint_key of an earlier version is used")
+	}
+
+	if _, err := v.Set("token", "y"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("fingerprint_key")) {
+		t.Errorf("the legacy fingerprint key was kept:\n%s", data)
+	}
+
+	if s, err = v.Snapshot(); err != nil || !s.CanFingerprint() {
+		t.Errorf("Snapshot() after Set() = %v, %v, want a new fingerprint key", s, err)
+	}
+}
@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"time"
+)
+
+// PreviousSecret is a secret replaced by a rotation. It is kept so that
+// consumers can accept both secrets until ExpiresAt.
+type PreviousSecret struct {
+	Secret    string     `json:"secret"`
+	RotatedAt time.Time  `json:"rotated_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsExpired reports whether the grace period of the previous secret is
+// over at now.
+func (p *PreviousSecret) IsExpired(now time.Time) bool {
+	return p.ExpiresAt != nil && !p.ExpiresAt.After(now)
+}
+
+// Rotate replaces the secret of the record with a new one generated with
+// g, or with the generator stored on the record when g is nil, and keeps
+// the old secret as Previous for the grace period. A grace period of
+// zero or less keeps the previous secret until the next rotation.
+func (r *SecretRecord) Rotate(g *Generator, grace time.Duration, now time.Time) error {
+	if g == nil {
+		g = r.Generator
+	}
+	if g == nil {
+		g = &Generator{}
+	}
+
+	value, err := g.Generate()
+	if err != nil {
+		return err
+	}
+
+	now = now.UTC()
+	r.Previous = &PreviousSecret{Secret: r.Secret, RotatedAt: now}
+	if grace > 0 {
+		expires := now.Add(grace)
+		r.Previous.ExpiresAt = &expires
+	}
+
+	r.Secret = value
+	r.Generator = g
+	r.UpdatedAt = now
+	return nil
+}
+
+// Rotate rotates the secret stored under key, see SecretRecord.Rotate,
+// and returns the updated record.
+func (v *Vault) Rotate(key string, g *Generator, grace time.Duration) (*SecretRecord, error) {
+	if err := checkKey(key); err != nil {
+		return nil, &Error{Op: "rotate", Path: v.path, Key: key, Err: err}
+	}
+
+	var record *SecretRecord
+	err := v.modify(func(data []byte) ([]byte, error) {
+		var err error
+		record, err = v.extract(data, key)
+		if err != nil {
+			return nil, err
+		}
+
+		before := *record
+		now := time.Now().UTC()
+		if err := record.Rotate(g, grace, now); err != nil {
+			return nil, err
+		}
+		v.track(record, &before, "rotate", now)
+
+		return v.put(data, key, record)
+	})
+	if err != nil {
+		return nil, &Error{Op: "rotate", Path: v.path, Key: key, Err: err}
+	}
+
+	return record, nil
+}
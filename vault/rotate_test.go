@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	v := newTestVault(t)
+
+	created, _, err := v.Ensure("token", &Generator{Size: 10, Chars: "abc"})
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+
+	rotated, err := v.Rotate("token", nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if len(rotated.Secret) != 10 || strings.Trim(rotated.Secret, "abc") != "" {
+		t.Errorf("Rotate() secret = %q, want 10 characters from the stored generator", rotated.Secret)
+	}
+
+	if rotated.Previous == nil || rotated.Previous.Secret != created.Secret {
+		t.Fatalf("Rotate() previous = %+v, want the old secret", rotated.Previous)
+	}
+
+	if rotated.Previous.ExpiresAt == nil || !rotated.Previous.ExpiresAt.Equal(rotated.Previous.RotatedAt.Add(time.Hour)) {
+		t.Errorf("Rotate() previous expires at %v, want an hour after the rotation", rotated.Previous.ExpiresAt)
+	}
+
+	if rotated.UpdatedAt.IsZero() || rotated.Previous.IsExpired(time.Now()) {
+		t.Error("Rotate() should set UpdatedAt and keep the previous secret for the grace period")
+	}
+
+	stored, err := v.Get("token")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if stored.Secret != rotated.Secret || stored.Previous.Secret != created.Secret {
+		t.Error("Rotate() did not store the rotated record")
+	}
+
+	again, err := v.Rotate("token", &Generator{Size: 4, Chars: "x"}, 0)
+	if err != nil {
+		t.Fatalf("Rotate() with a generator error = %v", err)
+	}
+
+	if again.Secret != "xxxx" || again.Generator.Size != 4 || again.Previous.ExpiresAt != nil {
+		t.Errorf("Rotate() = %+v, want the new generator stored and no grace period", again)
+	}
+
+	if _, err := v.Rotate("missing", nil, 0); err == nil {
+		t.Error("Rotate() of a missing key should fail")
+	}
+}
@@ -0,0 +1,757 @@
+// Package vault reads and writes xsops vaults: sops encrypted json files
+// that map keys to secret records.
+//
+// A vault is opened from a file path and every operation returns an
+// error instead of exiting, which allows xsops to be embedded in other
+// tools. Errors wrap one of the sentinel errors such as ErrKeyNotFound
+// and can be inspected with errors.Is.
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSymbols are the symbols used when generating secrets.
+const DefaultSymbols = "_-@#^~`|=+{}[]"
+
+// DefaultSize is the size of generated secrets.
+const DefaultSize int16 = 32
+
+// SecretRecord is the value stored for each key in a vault.
+type SecretRecord struct {
+	Secret    string             `json:"secret"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty"`
+	Tags      map[string]*string `json:"tags,omitempty"`
+	Enabled   bool               `json:"enabled"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+
+	// Generator holds the settings the secret was generated with.
+	Generator *Generator `json:"generator,omitempty"`
+
+	// Previous holds the secret replaced by the last rotation.
+	Previous *PreviousSecret `json:"previous,omitempty"`
+
+	// Version is the version number of the secret, ChangedBy and Change
+	// record who set it and how. History holds the prior versions,
+	// oldest first.
+	Version   int       `json:"version,omitempty"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	Change    string    `json:"change,omitempty"`
+	History   []Version `json:"history,omitempty"`
+}
+
+// IsExpired reports whether the record has an expiration time that is
+// not after now.
+func (r *SecretRecord) IsExpired(now time.Time) bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(now)
+}
+
+// Vault is a sops encrypted secrets file.
+type Vault struct {
+	path        string
+	backend     Backend
+	lockTimeout time.Duration
+	backupDir   string
+	backupKeep  int
+	readOnly    bool
+	historyKeep int
+	actor       string
+}
+
+// Option configures a vault when it is opened or created.
+type Option func(*Vault)
+
+// WithBackend sets the backend used to encrypt and decrypt the vault.
+// The default is the in-process SopsBackend.
+func WithBackend(b Backend) Option {
+	return func(v *Vault) {
+		v.backend = b
+	}
+}
+
+// WithReadOnly makes every change to the vault fail with ErrReadOnly.
+func WithReadOnly(readOnly bool) Option {
+	return func(v *Vault) {
+		v.readOnly = readOnly
+	}
+}
+
+func newVault(path string, opts []Option) *Vault {
+	v := &Vault{path: path, lockTimeout: DefaultLockTimeout, historyKeep: DefaultHistory}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	if v.backend == nil {
+		v.backend = &SopsBackend{}
+	}
+
+	return v
+}
+
+// Open opens the vault stored at path. The file must already exist.
+func Open(path string, opts ...Option) (*Vault, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, &Error{Op: "open", Path: path, Err: err}
+	}
+
+	if _, err := os.Stat(abs); err != nil {
+		if os.IsNotExist(err) {
+			return nil, &Error{Op: "open", Path: abs, Err: ErrVaultNotFound}
+		}
+		return nil, &Error{Op: "open", Path: abs, Err: err}
+	}
+
+	return newVault(abs, opts), nil
+}
+
+// Create creates an empty vault at path. With the sops backend the vault
+// is encrypted with the creation rules of the .sops.yaml file found in
+// the directory of path or one of its parents. It fails if the file
+// already exists.
+func Create(path string, opts ...Option) (*Vault, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, &Error{Op: "create", Path: path, Err: err}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return nil, &Error{Op: "create", Path: abs, Err: err}
+	}
+
+	v := newVault(abs, opts)
+	if v.readOnly {
+		return nil, &Error{Op: "create", Path: abs, Err: ErrReadOnly}
+	}
+
+	unlock, err := acquireLock(abs, v.lockTimeout)
+	if err != nil {
+		return nil, &Error{Op: "create", Path: abs, Err: err}
+	}
+	defer unlock()
+
+	if _, err := os.Stat(abs); err == nil {
+		return nil, &Error{Op: "create", Path: abs, Err: os.ErrExist}
+	}
+
+	out, err := v.encode(nil, nil, map[string]json.RawMessage{})
+	if err != nil {
+		return nil, &Error{Op: "create", Path: abs, Err: err}
+	}
+
+	if err := writeFile(abs, out, 0644); err != nil {
+		return nil, &Error{Op: "create", Path: abs, Err: err}
+	}
+
+	return v, nil
+}
+
+// Path returns the absolute path of the vault file.
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Get returns the record stored under key.
+func (v *Vault) Get(key string) (*SecretRecord, error) {
+	if err := checkKey(key); err != nil {
+		return nil, &Error{Op: "get", Path: v.path, Key: key, Err: err}
+	}
+
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return nil, &Error{Op: "get", Path: v.path, Key: key, Err: err}
+	}
+
+	record, err := v.extract(data, key)
+	if err != nil {
+		return nil, &Error{Op: "get", Path: v.path, Key: key, Err: err}
+	}
+
+	return record, nil
+}
+
+// List returns the keys in the vault in sorted order.
+func (v *Vault) List() ([]string, error) {
+	_, doc, err := v.decrypt()
+	if err != nil {
+		return nil, &Error{Op: "list", Path: v.path, Err: err}
+	}
+
+	records, err := flatten(doc)
+	if err != nil {
+		return nil, &Error{Op: "list", Path: v.path, Err: err}
+	}
+
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// All returns every record in the vault keyed by name, decrypting the
+// vault once.
+func (v *Vault) All() (map[string]*SecretRecord, error) {
+	_, doc, err := v.decrypt()
+	if err != nil {
+		return nil, &Error{Op: "list", Path: v.path, Err: err}
+	}
+
+	records, err := parseRecords(doc)
+	if err != nil {
+		return nil, &Error{Op: "list", Path: v.path, Err: err}
+	}
+
+	return records, nil
+}
+
+// UpdateOption configures how Update records its changes.
+type UpdateOption func(*updateOptions)
+
+type updateOptions struct {
+	change   string
+	replaced map[string]bool
+}
+
+// WithChange sets the change recorded in the history of the records
+// whose secrets are changed by Update. The default is "update".
+func WithChange(change string) UpdateOption {
+	return func(o *updateOptions) {
+		o.change = change
+	}
+}
+
+// withReplaced marks the records that fn stores under keys as replacing
+// the records that were there. They are written as is, keeping their
+// own version and history instead of continuing those of the records
+// they replace.
+func withReplaced(keys ...string) UpdateOption {
+	return func(o *updateOptions) {
+		o.replaced = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			o.replaced[key] = true
+		}
+	}
+}
+
+// Decode decrypts data, the contents of a vault file stored at path such
+// as an older version of the vault committed to git, and returns its
+// records and fingerprint key. The file at path is not read.
+func Decode(path string, data []byte, opts ...Option) (*Snapshot, error) {
+	v := newVault(path, opts)
+	doc, err := v.parse(data)
+	if err != nil {
+		return nil, &Error{Op: "decode", Path: path, Err: err}
+	}
+
+	s, err := newSnapshot(doc)
+	if err != nil {
+		return nil, &Error{Op: "decode", Path: path, Err: err}
+	}
+
+	return s, nil
+}
+
+// Update decrypts the vault once, calls fn with every record keyed by
+// name and, when fn returns nil, encrypts and writes the modified records
+// back in a single pass. Records added to or removed from the map are
+// added to or removed from the vault. Changed secrets are kept in the
+// history of their records.
+func (v *Vault) Update(fn func(records map[string]*SecretRecord) error, opts ...UpdateOption) error {
+	o := &updateOptions{change: "update"}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	err := v.modify(func(data []byte) ([]byte, error) {
+		doc, err := v.parse(data)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := parseRecords(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		before := make(map[string]SecretRecord, len(records))
+		for key, record := range records {
+			before[key] = *record
+		}
+
+		if err := fn(records); err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC()
+		flat := make(map[string]json.RawMessage, len(records))
+		for key, record := range records {
+			if err := checkKey(key); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+
+			switch old, ok := before[key]; {
+			case o.replaced[key]:
+			case ok:
+				v.track(record, &old, o.change, now)
+			default:
+				v.track(record, nil, o.change, now)
+			}
+
+			raw, err := json.Marshal(record)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			flat[key] = raw
+		}
+
+		nested, err := nest(flat)
+		if err != nil {
+			return nil, err
+		}
+
+		return v.encode(data, doc, nested)
+	})
+	if err != nil {
+		return &Error{Op: "update", Path: v.path, Err: err}
+	}
+
+	return nil
+}
+
+// Decrypt returns the decrypted vault document as indented json along
+// with the revision of the vault file it was read from.
+func (v *Vault) Decrypt() (plaintext []byte, rev string, err error) {
+	data, doc, err := v.decrypt()
+	if err != nil {
+		return nil, "", &Error{Op: "decrypt", Path: v.path, Err: err}
+	}
+
+	// The metadata is kept by Replace.
+	delete(doc, metadataKey)
+	plaintext, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", &Error{Op: "decrypt", Path: v.path, Err: err}
+	}
+
+	return plaintext, revision(data), nil
+}
+
+// Replace replaces the whole vault document with plaintext, a json object
+// of records such as the one returned by Decrypt, and encrypts it with the
+// existing data key and recipients of the vault.
+//
+// When rev is not empty the vault must still be at that revision, as
+// returned by Decrypt, otherwise ErrConflict is returned and the vault
+// is left unchanged.
+func (v *Vault) Replace(plaintext []byte, rev string) error {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(plaintext, &doc); err != nil {
+		return &Error{Op: "replace", Path: v.path, Err: err}
+	}
+
+	if _, err := parseRecords(doc); err != nil {
+		return &Error{Op: "replace", Path: v.path, Err: err}
+	}
+
+	flat, err := flatten(doc)
+	if err == nil {
+		doc, err = nest(flat)
+	}
+	if err != nil {
+		return &Error{Op: "replace", Path: v.path, Err: err}
+	}
+
+	err = v.modify(func(data []byte) ([]byte, error) {
+		if rev != "" && revision(data) != rev {
+			return nil, ErrConflict
+		}
+
+		current, err := v.parse(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return v.encode(data, current, doc)
+	})
+	if err != nil {
+		return &Error{Op: "replace", Path: v.path, Err: err}
+	}
+
+	return nil
+}
+
+// SetOption configures how Set updates a record.
+type SetOption func(*setOptions)
+
+type setOptions struct {
+	expiresAt *time.Time
+	tags      map[string]string
+}
+
+// WithExpiresAt sets the expiration time of the record.
+func WithExpiresAt(t time.Time) SetOption {
+	return func(o *setOptions) {
+		o.expiresAt = &t
+	}
+}
+
+// WithTags replaces the tags of the record.
+func WithTags(tags map[string]string) SetOption {
+	return func(o *setOptions) {
+		o.tags = tags
+	}
+}
+
+// Set stores value under key and returns the resulting record. New keys
+// create an enabled record; for existing keys an empty value keeps the
+// current secret and only the options are applied.
+func (v *Vault) Set(key, value string, opts ...SetOption) (*SecretRecord, error) {
+	if err := checkKey(key); err != nil {
+		return nil, &Error{Op: "set", Path: v.path, Key: key, Err: err}
+	}
+
+	o := &setOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var record *SecretRecord
+	err := v.modify(func(data []byte) ([]byte, error) {
+		var err error
+		var before *SecretRecord
+		now := time.Now().UTC()
+		record, err = v.extract(data, key)
+		switch {
+		case errors.Is(err, ErrKeyNotFound):
+			record = &SecretRecord{
+				Secret:    value,
+				CreatedAt: now,
+				Enabled:   true,
+			}
+		case err != nil:
+			return nil, err
+		default:
+			old := *record
+			before = &old
+			if value != "" {
+				record.Secret = value
+			}
+			record.UpdatedAt = now
+		}
+		v.track(record, before, "set", now)
+
+		if o.expiresAt != nil {
+			record.ExpiresAt = o.expiresAt
+		}
+
+		if o.tags != nil {
+			record.Tags = make(map[string]*string, len(o.tags))
+			for k, v := range o.tags {
+				record.Tags[k] = &v
+			}
+		}
+
+		return v.put(data, key, record)
+	})
+	if err != nil {
+		return nil, &Error{Op: "set", Path: v.path, Key: key, Err: err}
+	}
+
+	return record, nil
+}
+
+// Remove deletes key from the vault.
+func (v *Vault) Remove(key string) error {
+	if err := checkKey(key); err != nil {
+		return &Error{Op: "remove", Path: v.path, Key: key, Err: err}
+	}
+
+	err := v.modify(func(data []byte) ([]byte, error) {
+		doc, err := v.parse(data)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := flatten(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := records[key]; !ok {
+			return nil, ErrKeyNotFound
+		}
+
+		delete(records, key)
+		nested, err := nest(records)
+		if err != nil {
+			return nil, err
+		}
+
+		return v.encode(data, doc, nested)
+	})
+	if err != nil {
+		return &Error{Op: "remove", Path: v.path, Key: key, Err: err}
+	}
+
+	return nil
+}
+
+// Ensure returns the record stored under key. When the key does not
+// exist a new secret is generated with g, stored along with g so it can
+// be rotated later and returned; created reports whether that happened.
+// A nil g uses the zero Generator.
+func (v *Vault) Ensure(key string, g *Generator) (record *SecretRecord, created bool, err error) {
+	if err := checkKey(key); err != nil {
+		return nil, false, &Error{Op: "ensure", Path: v.path, Key: key, Err: err}
+	}
+
+	err = v.modify(func(data []byte) ([]byte, error) {
+		var err error
+		record, err = v.extract(data, key)
+		if err == nil {
+			return nil, nil
+		}
+
+		if !errors.Is(err, ErrKeyNotFound) {
+			return nil, err
+		}
+
+		if g == nil {
+			g = &Generator{}
+		}
+
+		value, err := g.Generate()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC()
+		record = &SecretRecord{
+			Secret:    value,
+			Generator: g,
+			CreatedAt: now,
+			Enabled:   true,
+		}
+		v.track(record, nil, "ensure", now)
+		created = true
+
+		return v.put(data, key, record)
+	})
+	if err != nil {
+		return nil, false, &Error{Op: "ensure", Path: v.path, Key: key, Err: err}
+	}
+
+	return record, created, nil
+}
+
+// decrypt reads the vault file and returns its bytes along with the
+// decrypted document.
+func (v *Vault) decrypt() ([]byte, map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := v.parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, doc, nil
+}
+
+// parse decrypts the vault file contents data into a document.
+func (v *Vault) parse(data []byte) (map[string]json.RawMessage, error) {
+	plain, err := v.backend.Decrypt(v.path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(plain, &doc); err != nil {
+		return nil, err
+	}
+
+	delete(doc, "sops")
+	return doc, nil
+}
+
+// modify reads the vault file while holding its lock and calls fn with
+// the contents. When fn returns new contents they are written back,
+// unless the file was changed in the meantime by a writer that does not
+// take the lock, such as the sops cli, in which case ErrConflict is
+// returned. Read-only vaults are not locked and return ErrReadOnly
+// instead of writing.
+func (v *Vault) modify(fn func(data []byte) ([]byte, error)) error {
+	if !v.readOnly {
+		unlock, err := acquireLock(v.path, v.lockTimeout)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+
+	out, err := fn(data)
+	if err != nil || out == nil {
+		return err
+	}
+
+	if v.readOnly {
+		return ErrReadOnly
+	}
+
+	return v.commit(data, out, true)
+}
+
+// commit atomically replaces the vault file, which must still hold data,
+// with out. The caller must hold the lock of the vault. When backup is
+// true data is kept as a backup first.
+func (v *Vault) commit(data, out []byte, backup bool) error {
+	current, err := os.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+
+	if revision(current) != revision(data) {
+		return ErrConflict
+	}
+
+	if backup {
+		if err := v.backup(data); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+	}
+
+	return writeFile(v.path, out, 0644)
+}
+
+// encode encrypts doc, replacing the current file contents data, and
+// returns the new file contents. The metadata of current, the decrypted
+// document of data, is kept; new metadata is created when it has no
+// fingerprint key.
+func (v *Vault) encode(data []byte, current, doc map[string]json.RawMessage) ([]byte, error) {
+	meta := current[metadataKey]
+	if !hasFingerprintKey(current) {
+		var err error
+		if meta, err = newMetadata(); err != nil {
+			return nil, err
+		}
+	}
+	doc[metadataKey] = meta
+
+	plain, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.backend.Encrypt(v.path, plain, data)
+}
+
+// extract decrypts the record stored under key in data. The backend
+// extracts the top level namespace of the key, which holds the record.
+func (v *Vault) extract(data []byte, key string) (*SecretRecord, error) {
+	segments, err := SplitKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := v.backend.Extract(v.path, data, segments[0])
+	if err == nil {
+		raw, err = lookup(raw, segments[1:])
+	}
+
+	if errors.Is(err, ErrKeyNotFound) && len(segments) > 1 {
+		// Keys with namespaces used to be stored flat.
+		if legacy, lerr := v.backend.Extract(v.path, data, key); lerr == nil && isRecord(legacy) {
+			raw, err = legacy, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	record := &SecretRecord{}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// put stores record under key in the current file contents data and
+// returns the new file contents. Only the top level namespace of the key
+// is replaced, unless the vault still has keys stored flat, which are
+// moved into their namespaces, or has no fingerprint key yet.
+func (v *Vault) put(data []byte, key string, record *SecretRecord) ([]byte, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := v.parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := flatten(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	records[key] = raw
+	nested, err := nest(records)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasFingerprintKey(doc) {
+		return v.encode(data, doc, nested)
+	}
+
+	for name := range doc {
+		if strings.Contains(name, Separator) {
+			return v.encode(data, doc, nested)
+		}
+	}
+
+	top, _, _ := strings.Cut(key, Separator)
+	return v.backend.Set(v.path, data, top, nested[top])
+}
+
+// parseRecords decodes the records of the nested document doc keyed by
+// their full keys.
+func parseRecords(doc map[string]json.RawMessage) (map[string]*SecretRecord, error) {
+	flat, err := flatten(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]*SecretRecord, len(flat))
+	for key, raw := range flat {
+		record := &SecretRecord{}
+		if err := json.Unmarshal(raw, record); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		records[key] = record
+	}
+
+	return records, nil
+}
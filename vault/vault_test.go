@@ -0,0 +1,344 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestVault(t *testing.T) *Vault {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "xsops.secrets.json")
+	v, err := Create(path, WithBackend(&PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	return v
+}
+
+func TestCreate(t *testing.T) {
+	v := newTestVault(t)
+
+	if _, err := os.Stat(v.Path()); err != nil {
+		t.Fatalf("vault file not created: %v", err)
+	}
+
+	keys, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(keys) != 0 {
+		t.Errorf("List() = %v, want empty", keys)
+	}
+
+	_, err = Create(v.Path(), WithBackend(&PlaintextBackend{}))
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("Create() on existing file error = %v, want os.ErrExist", err)
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "missing.json"), WithBackend(&PlaintextBackend{}))
+	if !errors.Is(err, ErrVaultNotFound) {
+		t.Errorf("Open() error = %v, want ErrVaultNotFound", err)
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	v := newTestVault(t)
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	created, err := v.Set("db-password", "hunter2", WithExpiresAt(expires), WithTags(map[string]string{"env": "prod"}))
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if !created.Enabled || created.CreatedAt.IsZero() {
+		t.Errorf("Set() new record = %+v, want enabled with created_at", created)
+	}
+
+	record, err := v.Get("db-password")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if record.Secret != "hunter2" {
+		t.Errorf("Get().Secret = %q, want %q", record.Secret, "hunter2")
+	}
+
+	if record.ExpiresAt == nil || !record.ExpiresAt.Equal(expires) {
+		t.Errorf("Get().ExpiresAt = %v, want %v", record.ExpiresAt, expires)
+	}
+
+	if tag := record.Tags["env"]; tag == nil || *tag != "prod" {
+		t.Errorf("Get().Tags = %v, want env=prod", record.Tags)
+	}
+
+	updated, err := v.Set("db-password", "")
+	if err != nil {
+		t.Fatalf("Set() update error = %v", err)
+	}
+
+	if updated.Secret != "hunter2" {
+		t.Errorf("Set() with empty value changed the secret to %q", updated.Secret)
+	}
+
+	if updated.UpdatedAt.IsZero() {
+		t.Error("Set() update did not set updated_at")
+	}
+
+	if !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Set() update changed created_at from %v to %v", created.CreatedAt, updated.CreatedAt)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	v := newTestVault(t)
+
+	if _, err := v.Get("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrKeyNotFound", err)
+	}
+
+	for _, key := range []string{"", "sops"} {
+		if _, err := v.Get(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	var verr *Error
+	_, err := v.Get("missing")
+	if !errors.As(err, &verr) || verr.Op != "get" || verr.Key != "missing" {
+		t.Errorf("Get(missing) error = %#v, want *Error with op and key", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	v := newTestVault(t)
+	for _, key := range []string{"b", "c", "a"} {
+		if _, err := v.Set(key, "value-"+key); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+
+	keys, err := v.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() = %v, want %v", keys, want)
+	}
+
+	records, err := v.All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+
+	if len(records) != 3 || records["b"].Secret != "value-b" {
+		t.Errorf("All() = %v, want three records", records)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := v.Remove("a"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	if _, err := v.Get("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get() after Remove() error = %v, want ErrKeyNotFound", err)
+	}
+
+	if err := v.Remove("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Remove() missing key error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	v := newTestVault(t)
+
+	record, created, err := v.Ensure("token", nil)
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+
+	if !created {
+		t.Error("Ensure() created = false for a new key")
+	}
+
+	if len(record.Secret) != int(DefaultSize) {
+		t.Errorf("Ensure() generated %d characters, want %d", len(record.Secret), DefaultSize)
+	}
+
+	again, created, err := v.Ensure("token", &Generator{Size: 12})
+	if err != nil {
+		t.Fatalf("Ensure() existing error = %v", err)
+	}
+
+	if created || again.Secret != record.Secret {
+		t.Errorf("Ensure() existing = (%q, %v), want (%q, false)", again.Secret, created, record.Secret)
+	}
+
+	digits, _, err := v.Ensure("digits", &Generator{Size: 8, Chars: "0123456789"})
+	if err != nil {
+		t.Fatalf("Ensure() with chars error = %v", err)
+	}
+
+	if len(digits.Secret) != 8 || strings.Trim(digits.Secret, "0123456789") != "" {
+		t.Errorf("Ensure() with chars = %q, want 8 digits", digits.Secret)
+	}
+
+	if digits.Generator == nil || digits.Generator.Chars != "0123456789" {
+		t.Errorf("Ensure() stored generator %+v, want the chars", digits.Generator)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	err := v.Update(func(records map[string]*SecretRecord) error {
+		delete(records, "a")
+		records["b"] = &SecretRecord{Secret: "2", Enabled: true}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	keys, _ := v.List()
+	if want := []string{"b"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() after Update() = %v, want %v", keys, want)
+	}
+
+	boom := errors.New("boom")
+	err = v.Update(func(records map[string]*SecretRecord) error {
+		records["c"] = &SecretRecord{Secret: "3"}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("Update() error = %v, want %v", err, boom)
+	}
+
+	if _, err := v.Get("c"); !errors.Is(err, ErrKeyNotFound) {
+		t.Error("Update() wrote the vault although fn failed")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("a", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	plain, rev, err := v.Decrypt()
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+
+	if err := v.Replace(plain, rev); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	if err := v.Replace([]byte(`{"a": "not a record"}`), ""); err == nil {
+		t.Error("Replace() accepted a value that is not a record")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("app/token", "1"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	data, err := os.ReadFile(v.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Set("app/token", "2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	snapshot, err := Decode(v.Path(), data, WithBackend(&PlaintextBackend{}))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	records := snapshot.Records
+	if len(records) != 1 || records["app/token"] == nil || records["app/token"].Secret != "1" {
+		t.Errorf("Decode() = %v, want app/token set to the old value", records)
+	}
+
+	if _, err := Decode(v.Path(), []byte("not json"), WithBackend(&PlaintextBackend{})); err == nil {
+		t.Error("Decode() accepted data that is not a vault")
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	tests := map[string]Backend{
+		"":          &SopsBackend{},
+		"sops":      &SopsBackend{},
+		"sops-exec": &ExecBackend{},
+		"plaintext": &PlaintextBackend{},
+		"memory":    &PlaintextBackend{},
+	}
+
+	for name, want := range tests {
+		got, err := NewBackend(name)
+		if err != nil {
+			t.Errorf("NewBackend(%q) error = %v", name, err)
+			continue
+		}
+
+		if reflect.TypeOf(got) != reflect.TypeOf(want) {
+			t.Errorf("NewBackend(%q) = %T, want %T", name, got, want)
+		}
+	}
+
+	if _, err := NewBackend("bogus"); err == nil {
+		t.Error("NewBackend(bogus) did not fail")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	v := newTestVault(t)
+	if _, err := v.Set("db-password", "hunter2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	ro, err := Open(v.Path(), WithBackend(&PlaintextBackend{}), WithReadOnly(true))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	if _, err := ro.Set("api-key", "secret"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Set() error = %v, want ErrReadOnly", err)
+	}
+
+	if err := ro.Remove("db-password"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Remove() error = %v, want ErrReadOnly", err)
+	}
+
+	record, created, err := ro.Ensure("db-password", nil)
+	if err != nil || created || record.Secret != "hunter2" {
+		t.Errorf("Ensure() = %v, %v, %v, want the existing record", record, created, err)
+	}
+
+	if _, _, err := ro.Ensure("api-key", nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Ensure() of a new key error = %v, want ErrReadOnly", err)
+	}
+}